@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/webhook"
+	"github.com/spf13/pflag"
+	"k8s.io/component-base/cli/flag"
+	"k8s.io/component-base/logs"
+	"k8s.io/klog/v2"
+)
+
+func main() {
+	var (
+		bindAddress string
+		certFile    string
+		keyFile     string
+	)
+
+	pflag.CommandLine.StringVar(&bindAddress, "bind-address", ":9443", "Address the webhook server listens on")
+	pflag.CommandLine.StringVar(&certFile, "tls-cert-file", "", "File containing the x509 certificate for HTTPS")
+	pflag.CommandLine.StringVar(&keyFile, "tls-private-key-file", "", "File containing the x509 private key matching --tls-cert-file")
+
+	logOptions := logging.NewOptions()
+	logOptions.AddFlags(pflag.CommandLine)
+
+	flag.InitFlags()
+	logs.InitLogs()
+	defer logs.FlushLogs()
+	if err := logOptions.ValidateAndApply(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if certFile == "" || keyFile == "" {
+		fmt.Fprintln(os.Stderr, "--tls-cert-file and --tls-private-key-file are required")
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:              bindAddress,
+		Handler:           webhook.NewHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	klog.InfoS("Serving MachineClass validation", "address", bindAddress)
+	if err := server.ListenAndServeTLS(certFile, keyFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
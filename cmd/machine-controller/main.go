@@ -22,11 +22,15 @@ Modifications Copyright (c) 2017 SAP SE or an SAP affiliate company. All rights
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
 	cp "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
 	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/tracing"
 	_ "github.com/gardener/machine-controller-manager/pkg/util/client/metrics/prometheus" // for client metric registration
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/app"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/app/options"
@@ -39,14 +43,82 @@ import (
 
 func main() {
 
+	var (
+		catalogRefreshInterval       time.Duration
+		reservationInventoryInterval time.Duration
+		tracingEndpoint              string
+		apiTimeouts                  = spi.DefaultTimeouts
+		apiQPS                       float64
+		apiBurst                     int
+		deviceListingCacheTTL        time.Duration
+		deviceIndexInterval          time.Duration
+		claimSettleDelay             time.Duration
+	)
+
 	s := options.NewMCServer()
 	s.AddFlags(pflag.CommandLine)
+	pflag.CommandLine.DurationVar(&catalogRefreshInterval, "plan-catalog-refresh-interval", 0,
+		"Interval for refreshing the catalog of plans and operating systems from the Equinix Metal API, 0 uses the embedded catalog only")
+	pflag.CommandLine.DurationVar(&reservationInventoryInterval, "reservation-inventory-interval", 0,
+		"Interval for exporting the utilisation of the hardware reservations of each MachineClass as metrics, 0 disables the inventory")
+	pflag.CommandLine.StringVar(&tracingEndpoint, "tracing-otlp-endpoint", "",
+		"OTLP/HTTP endpoint of an OpenTelemetry collector the traces are exported to, e.g. http://otel-collector:4318, tracing is disabled if empty")
+	pflag.CommandLine.DurationVar(&apiTimeouts.Create, "api-create-timeout", apiTimeouts.Create,
+		"Timeout of the Equinix Metal API calls creating devices, 0 disables the timeout")
+	pflag.CommandLine.DurationVar(&apiTimeouts.Get, "api-get-timeout", apiTimeouts.Get,
+		"Timeout of the Equinix Metal API calls getting a device or checking the capacity, 0 disables the timeout")
+	pflag.CommandLine.DurationVar(&apiTimeouts.List, "api-list-timeout", apiTimeouts.List,
+		"Timeout of the Equinix Metal API calls listing devices, plans, operating systems or hardware reservations, 0 disables the timeout")
+	pflag.CommandLine.DurationVar(&apiTimeouts.Delete, "api-delete-timeout", apiTimeouts.Delete,
+		"Timeout of the Equinix Metal API calls deleting devices, 0 disables the timeout")
+	pflag.CommandLine.Float64Var(&apiQPS, "api-qps", 5,
+		"Maximum number of Equinix Metal API calls per second and API token, listing calls may use half of it at most, 0 disables the rate limit")
+	pflag.CommandLine.IntVar(&apiBurst, "api-burst", 10,
+		"Maximum burst of Equinix Metal API calls per API token, at least 1 if the rate limit is enabled")
+	pflag.CommandLine.DurationVar(&deviceListingCacheTTL, "device-listing-cache-ttl", 10*time.Second,
+		"Time the device listing of a project is shared by the MachineClasses and used for status checks, 0 disables the cache")
+	pflag.CommandLine.DurationVar(&deviceIndexInterval, "device-index-interval", 0,
+		"Interval for listing the devices of the projects in the background to serve the status checks from, 0 disables the index")
+
+	pflag.CommandLine.DurationVar(&claimSettleDelay, "parked-device-claim-settle-delay", cp.DefaultClaimSettleDelay,
+		"Time a claim of a parked device has to persist before the device is reinstalled for a new machine, so that controllers claiming it concurrently are detected")
+
+	logOptions := logging.NewOptions()
+	logOptions.AddFlags(pflag.CommandLine)
 
 	flag.InitFlags()
 	logs.InitLogs()
 	defer logs.FlushLogs()
+	if err := logOptions.ValidateAndApply(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if tracingEndpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), "machine-controller-manager-provider-equinix-metal", tracingEndpoint)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = shutdown(context.Background()) }()
+	}
 
-	provider := cp.NewProvider(&spi.PluginSPIImpl{})
+	var sessionProvider spi.SessionProviderInterface = spi.NewInstrumentedSessionProvider(&spi.PluginSPIImpl{Timeouts: apiTimeouts})
+	if apiQPS > 0 {
+		if apiBurst < 1 {
+			fmt.Fprintf(os.Stderr, "invalid --api-burst %d: must be at least 1 if the rate limit is enabled\n", apiBurst)
+			os.Exit(1)
+		}
+		// the limiter wraps the instrumentation, so that the waiting time is not recorded as API latency
+		sessionProvider = spi.NewRateLimitedSessionProvider(sessionProvider, apiQPS, apiBurst, apiTimeouts)
+	}
+	provider := cp.NewProvider(sessionProvider,
+		cp.WithCatalogRefreshInterval(catalogRefreshInterval),
+		cp.WithReservationInventory(context.Background(), reservationInventoryInterval),
+		cp.WithDeviceListingCacheTTL(deviceListingCacheTTL),
+		cp.WithDeviceIndex(context.Background(), deviceIndexInterval),
+		cp.WithClaimSettleDelay(claimSettleDelay),
+	)
 
 	if err := app.Run(s, provider); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
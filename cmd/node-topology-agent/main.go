@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/topology"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/component-base/cli/flag"
+	"k8s.io/component-base/logs"
+	"k8s.io/klog/v2"
+)
+
+func main() {
+	var (
+		metadataURL     string
+		nodeName        string
+		kubeconfig      string
+		printNodeLabels bool
+		timeout         time.Duration
+	)
+
+	pflag.CommandLine.StringVar(&metadataURL, "metadata-url", topology.DefaultMetadataURL, "URL of the Equinix Metal metadata service")
+	pflag.CommandLine.StringVar(&nodeName, "node-name", os.Getenv("NODE_NAME"), "Name of the node to label, defaults to the hostname of the device")
+	pflag.CommandLine.StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig of the cluster of the node, the in-cluster configuration is used if empty")
+	pflag.CommandLine.BoolVar(&printNodeLabels, "print-node-labels", false, "Print the labels in the format of the kubelet --node-labels flag instead of labeling the node, e.g. in userData")
+	pflag.CommandLine.DurationVar(&timeout, "timeout", time.Minute, "Timeout for reading the metadata and labeling the node")
+
+	logOptions := logging.NewOptions()
+	logOptions.AddFlags(pflag.CommandLine)
+
+	flag.InitFlags()
+	logs.InitLogs()
+	defer logs.FlushLogs()
+	if err := logOptions.ValidateAndApply(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := run(ctx, metadataURL, nodeName, kubeconfig, printNodeLabels); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, metadataURL, nodeName, kubeconfig string, printNodeLabels bool) error {
+	metadata, err := topology.FetchMetadata(ctx, http.DefaultClient, metadataURL)
+	if err != nil {
+		return err
+	}
+	labels, err := topology.Labels(metadata)
+	if err != nil {
+		return err
+	}
+	if printNodeLabels {
+		fmt.Println(topology.FormatLabels(labels))
+		return nil
+	}
+
+	if nodeName == "" {
+		nodeName = metadata.Hostname
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	if err := topology.LabelNode(ctx, client, nodeName, labels); err != nil {
+		return err
+	}
+	klog.InfoS("Labeled node with its topology", "node", nodeName, "labels", labels)
+	return nil
+}
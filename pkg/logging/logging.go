@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package logging contains the keys of the structured log messages of the Equinix Metal provider,
+// so that logs can be filtered consistently by machine, device or project.
+package logging
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// KeyMachine is the key of the Machine name
+	KeyMachine = "machine"
+	// KeyMachineClass is the key of the MachineClass name
+	KeyMachineClass = "machineClass"
+	// KeyProviderID is the key of the ProviderID of a Machine
+	KeyProviderID = "providerID"
+	// KeyDeviceID is the key of the Equinix Metal device ID
+	KeyDeviceID = "deviceID"
+	// KeyProjectID is the key of the Equinix Metal project ID
+	KeyProjectID = "projectID"
+	// KeyMetro is the key of the Equinix Metal metro
+	KeyMetro = "metro"
+	// KeyPlan is the key of the Equinix Metal plan
+	KeyPlan = "plan"
+	// KeyReservationID is the key of the Equinix Metal hardware reservation ID
+	KeyReservationID = "reservationID"
+	// KeyRequestID is the key of the request ID returned by the Equinix Metal API
+	KeyRequestID = "requestID"
+	// KeyOperation is the key of the Equinix Metal API operation
+	KeyOperation = "operation"
+	// KeyAction is the key of the type of an action performed on a device
+	KeyAction = "action"
+)
+
+// WithValues adds the key value pairs to the logger of the context and returns the new context and logger
+func WithValues(ctx context.Context, keysAndValues ...interface{}) (context.Context, klog.Logger) {
+	logger := klog.FromContext(ctx).WithValues(keysAndValues...)
+	return klog.NewContext(ctx, logger), logger
+}
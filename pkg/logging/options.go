@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"github.com/spf13/pflag"
+	logsapi "k8s.io/component-base/logs/api/v1"
+	_ "k8s.io/component-base/logs/json/register" // for the JSON log format
+)
+
+// Options is the logging configuration of the binaries, e.g. --logging-format=json for JSON output
+type Options struct {
+	config *logsapi.LoggingConfiguration
+	flags  *pflag.FlagSet
+	// shared are the flags of the logging configuration that were already registered on the command line
+	shared map[string]*pflag.Flag
+}
+
+// NewOptions returns the default logging configuration
+func NewOptions() *Options {
+	o := &Options{
+		config: logsapi.NewLoggingConfiguration(),
+		flags:  pflag.NewFlagSet("logging", pflag.ContinueOnError),
+		shared: make(map[string]*pflag.Flag),
+	}
+	logsapi.AddFlags(o.config, o.flags)
+	return o
+}
+
+// AddFlags adds the flags of the logging configuration to fs. Flags already registered on fs, like the
+// -v flag of the controller options, are kept and their values are taken over by ValidateAndApply.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	o.flags.VisitAll(func(f *pflag.Flag) {
+		if existing := fs.Lookup(f.Name); existing != nil {
+			o.shared[f.Name] = existing
+			return
+		}
+		fs.AddFlag(f)
+	})
+}
+
+// ValidateAndApply validates the logging configuration and configures klog accordingly, it must be called after
+// the flags are parsed
+func (o *Options) ValidateAndApply() error {
+	for name, existing := range o.shared {
+		if err := o.flags.Set(name, existing.Value.String()); err != nil {
+			return err
+		}
+	}
+	return logsapi.ValidateAndApply(o.config, nil)
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logging_test
+
+import (
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/pflag"
+	"k8s.io/component-base/logs"
+	"k8s.io/klog/v2"
+)
+
+var _ = Describe("Options", func() {
+	parse := func(args ...string) (*logging.Options, error) {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		// registers -v, --vmodule and --log-flush-frequency like the controller options
+		logs.AddFlags(fs)
+		options := logging.NewOptions()
+		options.AddFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		return options, nil
+	}
+
+	AfterEach(func() {
+		options, err := parse()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options.ValidateAndApply()).To(Succeed())
+	})
+
+	It("should take over the verbosity of already registered flags", func() {
+		options, err := parse("-v=3", "--logging-format=json")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options.ValidateAndApply()).To(Succeed())
+
+		Expect(klog.V(3).Enabled()).To(BeTrue())
+		Expect(klog.V(4).Enabled()).To(BeFalse())
+	})
+
+	It("should reject unknown log formats", func() {
+		options, err := parse("--logging-format=xml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options.ValidateAndApply()).To(MatchError(ContainSubstring("xml")))
+	})
+})
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package metrics contains the Prometheus metrics of the Equinix Metal provider.
+// They are registered with the default registry, which is served on the metrics endpoint of the machine controller.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "mcm"
+	subsystem = "equinix_metal"
+)
+
+var (
+	// APIRequests counts the requests to the Equinix Metal API by operation.
+	APIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "api_requests_total",
+		Help:      "Number of requests to the Equinix Metal API.",
+	}, []string{"operation"})
+
+	// APIRequestErrors counts the failed requests to the Equinix Metal API by operation and HTTP status code.
+	// The code is "none" if the request failed without a response.
+	APIRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "api_request_errors_total",
+		Help:      "Number of failed requests to the Equinix Metal API.",
+	}, []string{"operation", "code"})
+
+	// APIRequestDuration is the latency of the requests to the Equinix Metal API by operation.
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of requests to the Equinix Metal API.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// APIRateLimitRemaining is the number of requests left in the current rate limit window,
+	// as reported by the last response of the Equinix Metal API.
+	APIRateLimitRemaining = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "api_rate_limit_remaining",
+		Help:      "Remaining requests in the rate limit window of the Equinix Metal API.",
+	})
+
+	// DeviceProvisioningDuration is the time from the creation of a device until it was first seen active.
+	DeviceProvisioningDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "device_provisioning_duration_seconds",
+		Help:      "Time from the creation of a device until it is active.",
+		Buckets:   []float64{60, 120, 180, 300, 450, 600, 900, 1200, 1800, 3600},
+	})
+
+	// HardwareReservations is the number of hardware reservations of a MachineClass by plan and metro, excluding spares.
+	HardwareReservations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "hardware_reservations",
+		Help:      "Number of hardware reservations, excluding spares.",
+	}, []string{"project", "machine_class", "plan", "metro"})
+
+	// HardwareReservationsInUse is the number of hardware reservations of a MachineClass with a device by plan and metro.
+	HardwareReservationsInUse = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "hardware_reservations_in_use",
+		Help:      "Number of hardware reservations with a provisioned device.",
+	}, []string{"project", "machine_class", "plan", "metro"})
+
+	// HardwareReservationsIdle is the number of hardware reservations of a MachineClass without a device by plan and metro.
+	HardwareReservationsIdle = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "hardware_reservations_idle",
+		Help:      "Number of hardware reservations without a device.",
+	}, []string{"project", "machine_class", "plan", "metro"})
+
+	// ReservationOnDemandFallbacks counts the devices created on demand because none of the hardware reservations
+	// of their MachineClass could be used.
+	ReservationOnDemandFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reservation_on_demand_fallbacks_total",
+		Help:      "Number of devices created on demand after all hardware reservations failed.",
+	}, []string{"machine_class"})
+
+	// DeviceListingCacheRequests counts the lookups of the device listing cache by result, "hit" or "miss".
+	DeviceListingCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "device_listing_cache_requests_total",
+		Help:      "Number of lookups of the cached project device listings by result.",
+	}, []string{"result"})
+
+	// DeviceIndexLookups counts the status lookups served by the device index by result, "hit" or "miss".
+	DeviceIndexLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "device_index_lookups_total",
+		Help:      "Number of device status lookups in the periodically listed device index by result.",
+	}, []string{"result"})
+
+	// MachineActions counts the actions requested by Machine annotations by action and result, "performed",
+	// "failed" or "unsupported".
+	MachineActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "machine_actions_total",
+		Help:      "Number of device actions requested by Machine annotations by result.",
+	}, []string{"action", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		APIRequests,
+		APIRequestErrors,
+		APIRequestDuration,
+		APIRateLimitRemaining,
+		DeviceProvisioningDuration,
+		HardwareReservations,
+		HardwareReservationsInUse,
+		HardwareReservationsIdle,
+		ReservationOnDemandFallbacks,
+		DeviceListingCacheRequests,
+		DeviceIndexLookups,
+		MachineActions,
+	)
+}
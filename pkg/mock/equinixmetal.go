@@ -17,8 +17,32 @@ import (
 // PluginSPIImpl is the plugin SPI implementation to mock the provider
 type PluginSPIImpl struct {
 	Devices []metalv1.Device
-	index   int
-	mu      sync.Mutex // so that we can increment index without conflicts
+	// NoCapacity contains the "metro/plan" combinations without capacity, all others have capacity
+	NoCapacity map[string]bool
+	// NoQuota contains the projects in which no device can be created, all others have quota
+	NoQuota map[string]bool
+	// Plans and OperatingSystems are the slugs returned by the catalog lookups
+	Plans            []string
+	OperatingSystems []string
+	// FailCatalog makes the catalog lookups fail
+	FailCatalog bool
+	// CatalogLookups is the number of plan lookups
+	CatalogLookups int
+	// HardwareReservations are the reservations of the project, devices created with one of them are assigned to it
+	HardwareReservations []metalv1.HardwareReservation
+	// SSHKeys are the SSH keys of the project
+	SSHKeys []metalv1.SSHKey
+	// Actions are the actions performed on the devices in order, formatted as "<device id>/<action type>"
+	Actions []string
+	// FailActions contains the action types that fail without being performed
+	FailActions map[metalv1.DeviceActionInputType]bool
+	// AfterUpdateDevice is called with each updated device, e.g. to emulate concurrent updates of other clients
+	AfterUpdateDevice func(device *metalv1.Device)
+	// AfterFindDevice is called with each device found by its ID once it has been read, e.g. to emulate concurrent
+	// updates of other clients
+	AfterFindDevice func(device *metalv1.Device)
+	index           int
+	mu              sync.Mutex // so that we can increment index without conflicts
 }
 
 // NewSession creates a mock session for provider
@@ -49,6 +73,18 @@ func (p *PluginSPIImpl) addDevice(dev metalv1.Device) {
 	p.Devices = append(p.Devices, dev)
 }
 
+func (p *PluginSPIImpl) findReservation(id *string) *metalv1.HardwareReservation {
+	if id == nil {
+		return nil
+	}
+	for i := range p.HardwareReservations {
+		if p.HardwareReservations[i].GetId() == *id {
+			return &p.HardwareReservations[i]
+		}
+	}
+	return nil
+}
+
 type deviceService struct {
 	spi   *PluginSPIImpl
 	name  string
@@ -59,8 +95,15 @@ func (d *deviceService) FindProjectDevices(
 	ctx context.Context,
 	projectID string,
 ) (*metalv1.DeviceList, *http.Response, error) {
+	// devices without project belong to every project
+	devices := []metalv1.Device{}
+	for _, dev := range d.spi.Devices {
+		if dev.Project == nil || dev.Project.GetId() == projectID {
+			devices = append(devices, dev)
+		}
+	}
 	return &metalv1.DeviceList{
-		Devices: d.spi.Devices,
+		Devices: devices,
 	}, &http.Response{}, nil
 }
 
@@ -68,8 +111,11 @@ func (d *deviceService) FindDeviceByID(
 	ctx context.Context,
 	deviceID string,
 ) (*metalv1.Device, *http.Response, error) {
-	for _, dev := range d.spi.Devices {
+	for i, dev := range d.spi.Devices {
 		if *dev.Id == deviceID {
+			if d.spi.AfterFindDevice != nil {
+				d.spi.AfterFindDevice(&d.spi.Devices[i])
+			}
 			return &dev, &http.Response{}, nil
 		}
 	}
@@ -84,9 +130,22 @@ func (d *deviceService) CreateDevice(
 	projectID string,
 	createDeviceRequest metalv1.CreateDeviceRequest,
 ) (*metalv1.Device, *http.Response, error) {
+	if d.spi.NoQuota[projectID] {
+		return nil, &http.Response{
+			StatusCode: 403,
+			Status:     "403 FORBIDDEN",
+		}, fmt.Errorf("403 project %s has reached its device quota", projectID)
+	}
 	now := time.Now()
-	d.spi.increment()
 	req := createDeviceRequest.DeviceCreateInMetroInput
+	reservation := d.spi.findReservation(req.HardwareReservationId)
+	if reservation != nil && reservation.Device != nil {
+		return nil, &http.Response{
+			StatusCode: 422,
+			Status:     "422 UNPROCESSABLE ENTITY",
+		}, fmt.Errorf("422 hardware reservation %s is already in use", *req.HardwareReservationId)
+	}
+	d.spi.increment()
 	var (
 		name         = fmt.Sprintf("%06d", d.spi.index)
 		billingCycle = string(*req.BillingCycle)
@@ -102,25 +161,64 @@ func (d *deviceService) CreateDevice(
 		OperatingSystem: &metalv1.OperatingSystem{
 			Name: &req.OperatingSystem,
 		},
-		Plan: &metalv1.Plan{},
+		Plan: &metalv1.Plan{
+			Slug: &req.Plan,
+		},
 		Metro: &metalv1.DeviceMetro{
 			Code: &req.Metro,
 		},
 		Project: &metalv1.Project{
 			Id: &projectID,
 		},
-		Userdata: req.Userdata,
+		Userdata:      req.Userdata,
+		Locked:        req.Locked,
+		AlwaysPxe:     req.AlwaysPxe,
+		SshKeys:       d.sshKeys(req),
+		Customdata:    req.Customdata,
+		IpxeScriptUrl: req.IpxeScriptUrl,
+	}
+	if reservation != nil {
+		dev.HardwareReservation = &metalv1.HardwareReservation{Id: reservation.Id}
+		reservation.Device = &metalv1.Device{Id: dev.Id}
 	}
 	d.spi.addDevice(dev)
 	return &dev, &http.Response{}, nil
 }
 
+// sshKeys returns the references to the SSH keys injected into a new device, which are all project keys if none
+// are selected and they are not disabled
+func (d *deviceService) sshKeys(req *metalv1.DeviceCreateInMetroInput) []metalv1.Href {
+	var keys []metalv1.Href
+	if req.GetNoSshKeys() {
+		return keys
+	}
+	projectKeys := req.ProjectSshKeys
+	if len(projectKeys) == 0 && len(req.UserSshKeys) == 0 {
+		for _, key := range d.spi.SSHKeys {
+			projectKeys = append(projectKeys, key.GetId())
+		}
+	}
+	for _, id := range projectKeys {
+		keys = append(keys, metalv1.Href{Href: "/metal/v1/ssh-keys/" + id})
+	}
+	for _, id := range req.UserSshKeys {
+		keys = append(keys, metalv1.Href{Href: "/metal/v1/users/" + id})
+	}
+	return keys
+}
+
 func (d *deviceService) DeleteDevice(
 	ctx context.Context,
 	deviceID string,
 ) (*http.Response, error) {
 	var devs []metalv1.Device
 	for _, dev := range d.spi.Devices {
+		if *dev.Id == deviceID && dev.GetLocked() {
+			return &http.Response{
+				StatusCode: 422,
+				Status:     "422 UNPROCESSABLE ENTITY",
+			}, fmt.Errorf("422 cannot delete a locked device")
+		}
 		if *dev.Id != deviceID {
 			devs = append(devs, dev)
 		}
@@ -128,3 +226,142 @@ func (d *deviceService) DeleteDevice(
 	d.spi.Devices = devs
 	return &http.Response{}, nil
 }
+
+func (d *deviceService) UpdateDevice(
+	ctx context.Context,
+	deviceID string,
+	updateDeviceInput metalv1.DeviceUpdateInput,
+) (*metalv1.Device, *http.Response, error) {
+	d.spi.mu.Lock()
+	defer d.spi.mu.Unlock()
+	for i := range d.spi.Devices {
+		dev := &d.spi.Devices[i]
+		if dev.GetId() != deviceID {
+			continue
+		}
+		if updateDeviceInput.Hostname != nil {
+			dev.Hostname = updateDeviceInput.Hostname
+		}
+		if updateDeviceInput.Description != nil {
+			dev.Description = updateDeviceInput.Description
+		}
+		if updateDeviceInput.Userdata != nil {
+			dev.Userdata = updateDeviceInput.Userdata
+		}
+		if updateDeviceInput.Tags != nil {
+			dev.Tags = updateDeviceInput.Tags
+		}
+		if updateDeviceInput.Customdata != nil {
+			dev.Customdata = updateDeviceInput.Customdata
+		}
+		if updateDeviceInput.Locked != nil {
+			dev.Locked = updateDeviceInput.Locked
+		}
+		if d.spi.AfterUpdateDevice != nil {
+			d.spi.AfterUpdateDevice(dev)
+		}
+		updated := *dev
+		return &updated, &http.Response{}, nil
+	}
+	return nil, &http.Response{
+		StatusCode: 404,
+		Status:     "404 NOT FOUND",
+	}, fmt.Errorf("404 NOT FOUND")
+}
+
+func (d *deviceService) PerformAction(
+	ctx context.Context,
+	deviceID string,
+	actionInput metalv1.DeviceActionInput,
+) (*http.Response, error) {
+	d.spi.mu.Lock()
+	defer d.spi.mu.Unlock()
+	for i := range d.spi.Devices {
+		dev := &d.spi.Devices[i]
+		if dev.GetId() != deviceID {
+			continue
+		}
+		if d.spi.FailActions[actionInput.Type] {
+			return &http.Response{
+				StatusCode: 500,
+				Status:     "500 INTERNAL SERVER ERROR",
+			}, fmt.Errorf("500 %s failed", actionInput.Type)
+		}
+		d.spi.Actions = append(d.spi.Actions, deviceID+"/"+string(actionInput.Type))
+		if actionInput.Type == metalv1.DEVICEACTIONINPUTTYPE_REINSTALL {
+			dev.State = metalv1.DEVICESTATE_REINSTALLING.Ptr()
+			if actionInput.OperatingSystem != nil {
+				dev.OperatingSystem = &metalv1.OperatingSystem{Name: actionInput.OperatingSystem}
+			}
+		}
+		return &http.Response{}, nil
+	}
+	return &http.Response{
+		StatusCode: 404,
+		Status:     "404 NOT FOUND",
+	}, fmt.Errorf("404 NOT FOUND")
+}
+
+func (d *deviceService) CheckCapacity(
+	ctx context.Context,
+	servers []metalv1.ServerInfo,
+) (*metalv1.CapacityCheckPerMetroList, *http.Response, error) {
+	list := &metalv1.CapacityCheckPerMetroList{}
+	for _, s := range servers {
+		available := !d.spi.NoCapacity[s.GetMetro()+"/"+s.GetPlan()]
+		list.Servers = append(list.Servers, metalv1.CapacityCheckPerMetroInfo{
+			Available: &available,
+			Metro:     s.Metro,
+			Plan:      s.Plan,
+			Quantity:  s.Quantity,
+		})
+	}
+	return list, &http.Response{}, nil
+}
+
+func (d *deviceService) FindPlans(
+	ctx context.Context,
+) (*metalv1.PlanList, *http.Response, error) {
+	d.spi.mu.Lock()
+	d.spi.CatalogLookups++
+	d.spi.mu.Unlock()
+	if d.spi.FailCatalog {
+		return nil, nil, errors.New("catalog unavailable")
+	}
+	list := &metalv1.PlanList{}
+	for i := range d.spi.Plans {
+		list.Plans = append(list.Plans, metalv1.Plan{Slug: &d.spi.Plans[i]})
+	}
+	return list, &http.Response{}, nil
+}
+
+func (d *deviceService) FindOperatingSystems(
+	ctx context.Context,
+) (*metalv1.OperatingSystemList, *http.Response, error) {
+	if d.spi.FailCatalog {
+		return nil, nil, errors.New("catalog unavailable")
+	}
+	list := &metalv1.OperatingSystemList{}
+	for i := range d.spi.OperatingSystems {
+		list.OperatingSystems = append(list.OperatingSystems, metalv1.OperatingSystem{Slug: &d.spi.OperatingSystems[i]})
+	}
+	return list, &http.Response{}, nil
+}
+
+func (d *deviceService) FindProjectHardwareReservations(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.HardwareReservationList, *http.Response, error) {
+	return &metalv1.HardwareReservationList{
+		HardwareReservations: d.spi.HardwareReservations,
+	}, &http.Response{}, nil
+}
+
+func (d *deviceService) FindProjectSSHKeys(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.SSHKeyList, *http.Response, error) {
+	return &metalv1.SSHKeyList{
+		SshKeys: d.spi.SSHKeys,
+	}, &http.Response{}, nil
+}
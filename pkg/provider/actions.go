@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"k8s.io/klog/v2"
+)
+
+// machineActions are the device actions that can be requested with the api.AnnotationAction annotation of a Machine
+var machineActions = map[string]func(context.Context, spi.MetalDeviceService, string) (*http.Response, error){
+	api.MachineActionReboot:   spi.RebootDevice,
+	api.MachineActionPowerOff: spi.PowerOffDevice,
+	api.MachineActionPowerOn:  spi.PowerOnDevice,
+	api.MachineActionRescue:   spi.RescueDevice,
+}
+
+// performRequestedAction performs the action requested by the action annotation of the machine on its device, unless
+// the same annotation value has been performed already. The value is recorded in a tag of the device before the
+// action, so that it is performed once even across restarts. Failures are logged only, so that they do not affect
+// the status of the machine, and the action is retried with the next status check.
+func (p *Provider) performRequestedAction(ctx context.Context, svc spi.MetalDeviceService, machine *v1alpha1.Machine, device *metalv1.Device) {
+	value := strings.TrimSpace(machine.Annotations[api.AnnotationAction])
+	if value == "" {
+		return
+	}
+	id := device.GetId()
+	p.mu.Lock()
+	performed := p.actions[id] == value
+	p.mu.Unlock()
+	if performed || actionTag(device.Tags) == value {
+		return
+	}
+
+	action, _, _ := strings.Cut(value, "/")
+	logger := klog.FromContext(ctx).WithValues(logging.KeyAction, action)
+	perform, ok := machineActions[action]
+	if !ok {
+		logger.Info("Ignoring unsupported machine action", "annotation", api.AnnotationAction, "value", value)
+		metrics.MachineActions.WithLabelValues(action, "unsupported").Inc()
+		p.performedAction(id, value)
+		return
+	}
+
+	// the device may come from a listing, so its tags are read again to not overwrite tags written since
+	current, _, err := svc.FindDeviceByID(ctx, id)
+	if err != nil {
+		logger.Error(err, "Could not get device to record machine action")
+		metrics.MachineActions.WithLabelValues(action, "failed").Inc()
+		return
+	}
+	previous := actionTag(current.Tags)
+	if previous == value {
+		p.performedAction(id, value)
+		return
+	}
+	if _, _, err := svc.UpdateDevice(ctx, id, metalv1.DeviceUpdateInput{Tags: withActionTag(current.Tags, value)}); err != nil {
+		logger.Error(err, "Could not record machine action")
+		metrics.MachineActions.WithLabelValues(action, "failed").Inc()
+		return
+	}
+	if _, err := perform(ctx, svc, id); err != nil {
+		logger.Error(err, "Could not perform machine action")
+		metrics.MachineActions.WithLabelValues(action, "failed").Inc()
+		// restore the previous record, so that the action is retried
+		if current, _, err = svc.FindDeviceByID(ctx, id); err == nil {
+			_, _, err = svc.UpdateDevice(ctx, id, metalv1.DeviceUpdateInput{Tags: withActionTag(current.Tags, previous)})
+		}
+		if err != nil {
+			logger.Error(err, "Could not remove record of failed machine action")
+		}
+		return
+	}
+	metrics.MachineActions.WithLabelValues(action, "performed").Inc()
+	p.performedAction(id, value)
+	logger.V(2).Info("Performed machine action", "value", value)
+}
+
+// performedAction records the value of the action annotation performed on the device
+func (p *Provider) performedAction(deviceID, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.actions[deviceID] = value
+}
+
+// forgetDevice drops the deleted or parked device from the device listings, the performed actions and the stamped
+// topologies
+func (p *Provider) forgetDevice(deviceID string) {
+	p.devices.forget(deviceID)
+	p.index.forget(deviceID)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.actions, deviceID)
+	delete(p.topologies, deviceID)
+}
+
+// actionTag returns the value of the action tag of the device tags, if any
+func actionTag(tags []string) string {
+	for _, tag := range api.ParseTags(tags) {
+		if tag.Key == api.TagKeyAction {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// withActionTag returns the device tags with the action tag set to value, an empty value removes the tag
+func withActionTag(tags []string, value string) []string {
+	var result []string
+	if value != "" {
+		result = append(result, api.Tag{Key: api.TagKeyAction, Value: value}.String())
+	}
+	for _, tag := range tags {
+		if api.ParseTag(tag).Key != api.TagKeyAction {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
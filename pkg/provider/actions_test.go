@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MachineActions", func() {
+	var (
+		ctx     = context.Background()
+		plugin  *mock.PluginSPIImpl
+		machine *v1alpha1.Machine
+		id      string
+	)
+	providerSecret := newProviderSecret()
+	providerSpec := newProviderSpec(nil)
+	// getMachineStatus checks the status of the machine with the given action annotation value
+	getMachineStatus := func(p driver.Driver, value string) {
+		machine.Annotations = map[string]string{api.AnnotationAction: value}
+		_, err := p.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		plugin = &mock.PluginSPIImpl{}
+		machine = newMachine(1)
+		resp, err := provider.NewProvider(plugin).CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		machine.Spec.ProviderID = resp.ProviderID
+		id = plugin.Devices[0].GetId()
+	})
+
+	table.DescribeTable("should perform the requested action once",
+		func(value, action string) {
+			p := provider.NewProvider(plugin)
+			getMachineStatus(p, value)
+			getMachineStatus(p, value)
+			Expect(plugin.Actions).To(Equal([]string{id + "/" + action}))
+			Expect(plugin.Devices[0].Tags).To(ContainElement(api.TagKeyAction + ": " + value))
+			Expect(plugin.Devices[0].Tags).To(ContainElement("kubernetes.io/cluster/shoot-test: 1"))
+		},
+		table.Entry("reboot", "reboot", "reboot"),
+		table.Entry("power off", "power-off", "power_off"),
+		table.Entry("power on", "power-on", "power_on"),
+		table.Entry("rescue with suffix", "rescue/1", "rescue"),
+	)
+
+	It("should not repeat an action after a restart", func() {
+		getMachineStatus(provider.NewProvider(plugin), "reboot")
+		getMachineStatus(provider.NewProvider(plugin), "reboot")
+		Expect(plugin.Actions).To(Equal([]string{id + "/reboot"}))
+	})
+
+	It("should repeat an action with a changed value", func() {
+		p := provider.NewProvider(plugin)
+		getMachineStatus(p, "reboot/1")
+		getMachineStatus(p, "reboot/2")
+		Expect(plugin.Actions).To(Equal([]string{id + "/reboot", id + "/reboot"}))
+		Expect(plugin.Devices[0].Tags).To(ContainElement(api.TagKeyAction + ": reboot/2"))
+		Expect(plugin.Devices[0].Tags).NotTo(ContainElement(api.TagKeyAction + ": reboot/1"))
+	})
+
+	It("should keep the tags written since the device was read", func() {
+		plugin.AfterFindDevice = func(device *metalv1.Device) {
+			device.Tags = append(device.Tags, "concurrent: 1")
+			plugin.AfterFindDevice = nil
+		}
+		getMachineStatus(provider.NewProvider(plugin), "reboot")
+		Expect(plugin.Actions).To(Equal([]string{id + "/reboot"}))
+		Expect(plugin.Devices[0].Tags).To(ContainElements(api.TagKeyAction+": reboot", "concurrent: 1"))
+	})
+
+	It("should ignore unsupported actions", func() {
+		getMachineStatus(provider.NewProvider(plugin), "explode")
+		Expect(plugin.Actions).To(BeEmpty())
+	})
+
+	It("should not perform actions without annotation", func() {
+		getMachineStatus(provider.NewProvider(plugin), "")
+		Expect(plugin.Actions).To(BeEmpty())
+	})
+})
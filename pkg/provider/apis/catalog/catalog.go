@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package catalog contains the catalog of Equinix Metal plans, billing cycles and operating systems
+// that provider specs are validated against.
+package catalog
+
+import (
+	_ "embed" // for the embedded catalog
+	"encoding/json"
+	"sort"
+)
+
+// maxSuggestionDistance is the maximum edit distance for a known value to be suggested for an unknown one
+const maxSuggestionDistance = 2
+
+//go:embed catalog.json
+var embeddedCatalog []byte
+
+// Catalog is a set of known plan, billing cycle and operating system slugs.
+type Catalog struct {
+	BillingCycles    []string `json:"billingCycles"`
+	Plans            []string `json:"plans"`
+	OperatingSystems []string `json:"operatingSystems"`
+}
+
+// embedded is the catalog shipped with the provider, it must not be modified
+var embedded = mustLoadEmbedded()
+
+func mustLoadEmbedded() *Catalog {
+	c := &Catalog{}
+	if err := json.Unmarshal(embeddedCatalog, c); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Embedded returns a copy of the catalog shipped with the provider.
+func Embedded() *Catalog {
+	return &Catalog{
+		BillingCycles:    append([]string{}, embedded.BillingCycles...),
+		Plans:            append([]string{}, embedded.Plans...),
+		OperatingSystems: append([]string{}, embedded.OperatingSystems...),
+	}
+}
+
+// Merge returns a copy of the catalog with the given plans and operating systems, e.g. fetched from the Equinix
+// Metal API, added. Known values are never removed, so that a partial API response cannot invalidate existing
+// provider specs.
+func (c *Catalog) Merge(plans, operatingSystems []string) *Catalog {
+	return &Catalog{
+		BillingCycles:    append([]string{}, c.BillingCycles...),
+		Plans:            union(c.Plans, plans),
+		OperatingSystems: union(c.OperatingSystems, operatingSystems),
+	}
+}
+
+// HasPlan returns true if the plan slug is known.
+func (c *Catalog) HasPlan(plan string) bool {
+	return contains(c.Plans, plan)
+}
+
+// HasBillingCycle returns true if the billing cycle is known.
+func (c *Catalog) HasBillingCycle(billingCycle string) bool {
+	return contains(c.BillingCycles, billingCycle)
+}
+
+// HasOperatingSystem returns true if the operating system slug is known.
+func (c *Catalog) HasOperatingSystem(os string) bool {
+	return contains(c.OperatingSystems, os)
+}
+
+// Suggest returns the known values closest to the given unknown value, best match first.
+func Suggest(value string, known []string) []string {
+	type candidate struct {
+		value    string
+		distance int
+	}
+	var candidates []candidate
+	for _, k := range known {
+		if d := distance(value, k); d <= maxSuggestionDistance {
+			candidates = append(candidates, candidate{value: k, distance: d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	suggestions := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		suggestions = append(suggestions, c.value)
+	}
+	return suggestions
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minOf(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minOf(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func union(a, b []string) []string {
+	result := append([]string{}, a...)
+	for _, v := range b {
+		if v != "" && !contains(result, v) {
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
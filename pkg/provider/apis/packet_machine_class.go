@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PacketMachineClass is the legacy machine class of the in-tree Packet driver of the machine-controller-manager.
+// It is only used to migrate existing PacketMachineClasses to MachineClasses.
+type PacketMachineClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PacketMachineClassSpec `json:"spec,omitempty"`
+}
+
+// PacketMachineClassSpec is the specification of a legacy PacketMachineClass.
+type PacketMachineClassSpec struct {
+	Facility     []string `json:"facility"`
+	MachineType  string   `json:"machineType"`
+	BillingCycle string   `json:"billingCycle"`
+	OS           string   `json:"OS"`
+	ProjectID    string   `json:"projectID"`
+	Tags         []string `json:"tags,omitempty"`
+	SSHKeys      []string `json:"sshKeys,omitempty"`
+	UserData     string   `json:"userdata,omitempty"`
+
+	SecretRef            *corev1.SecretReference `json:"secretRef,omitempty"`
+	CredentialsSecretRef *corev1.SecretReference `json:"credentialsSecretRef,omitempty"`
+}
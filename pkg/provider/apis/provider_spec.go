@@ -4,6 +4,8 @@
 
 package api
 
+import "encoding/json"
+
 const (
 	// APIKey is a constant for a key name that is part of the equinix metal cloud credentials
 	APIKey string = "apiToken"
@@ -12,9 +14,87 @@ const (
 	AlternateAPIKey string = "alternateApiToken"
 	// V1alpha1 is the API version
 	V1alpha1 string = "mcm.gardener.cloud/v1alpha1"
+	// V1alpha2 is the API version with structured placement, machine, operating system and reservation fields
+	V1alpha2 string = "mcm.gardener.cloud/v1alpha2"
+
+	// ProviderIDFormatMetro is the default ProviderID format "equinixmetal://<metro>/<device id>"
+	ProviderIDFormatMetro string = "metro"
+	// ProviderIDFormatProject is the ProviderID format "equinixmetal://<project id>/<metro>/<device id>"
+	ProviderIDFormatProject string = "project"
+	// ProviderIDFormatCCM is the ProviderID format "equinixmetal://<device id>" set by the Equinix Metal
+	// cloud-controller-manager
+	ProviderIDFormatCCM string = "ccm"
+	// ProviderIDFormatPacket is the legacy ProviderID format "packet://<device id>" of the in-tree Packet driver
+	ProviderIDFormatPacket string = "packet"
+
+	// OSCustomIPXE is the operating system booting the device with a custom iPXE script
+	OSCustomIPXE string = "custom_ipxe"
+
+	// TagKeyMachineName is the tag key carrying the name of the Machine backing a device
+	TagKeyMachineName string = "mcm.gardener.cloud/machine"
+	// TagKeyNamespace is the tag key carrying the namespace of the Machine backing a device
+	TagKeyNamespace string = "mcm.gardener.cloud/namespace"
+	// TagKeyMachineClass is the tag key carrying the name of the MachineClass a device was created from
+	TagKeyMachineClass string = "mcm.gardener.cloud/machineclass"
+
+	// TagKeyProviderIDFormat is the tag key recording the ProviderID format of the Machine backing a device
+	TagKeyProviderIDFormat string = "equinixmetal.gardener.cloud/provider-id-format"
+
+	// TagKeyMetro is the tag key carrying the metro of a device, the topology.kubernetes.io/region of its node
+	TagKeyMetro string = "equinixmetal.gardener.cloud/metro"
+	// TagKeyFacility is the tag key carrying the facility of a device, the topology.kubernetes.io/zone of its node
+	TagKeyFacility string = "equinixmetal.gardener.cloud/facility"
+	// CustomDataKeyTopology is the customdata key of the object carrying the "metro" and "facility" of a device
+	CustomDataKeyTopology string = "topology"
+
+	// TagKeyFreePool is the tag key marking the reserved devices parked for reuse by the reinstall replacement strategy
+	TagKeyFreePool string = "equinixmetal.gardener.cloud/free-pool"
+	// TagKeySSHKeys is the tag key recording a fingerprint of the SSH keys of a device created with the reinstall
+	// replacement strategy, parked devices keep their SSH keys and are only reused for the same SSH keys
+	TagKeySSHKeys string = "equinixmetal.gardener.cloud/ssh-keys"
+
+	// TagKeyAction is the tag key recording the value of the last AnnotationAction performed on a device
+	TagKeyAction string = "equinixmetal.gardener.cloud/action"
+	// AnnotationAction is the Machine annotation requesting an action on its device, one of the MachineAction values
+	// optionally followed by "/" and an arbitrary suffix. Each value is performed once, so a changed suffix repeats
+	// the action.
+	AnnotationAction string = "equinixmetal.gardener.cloud/action"
+
+	// MachineActionReboot power-cycles the device
+	MachineActionReboot string = "reboot"
+	// MachineActionPowerOff powers the device off
+	MachineActionPowerOff string = "power-off"
+	// MachineActionPowerOn powers the device on
+	MachineActionPowerOn string = "power-on"
+	// MachineActionRescue reboots the device into the rescue operating system
+	MachineActionRescue string = "rescue"
+
+	// ProjectPlacementOrdered is the default project placement, the projects are tried in order
+	ProjectPlacementOrdered string = "ordered"
+	// ProjectPlacementWeighted is the project placement choosing the projects randomly by their weight
+	ProjectPlacementWeighted string = "weighted"
+
+	// ReplacementStrategyRecreate is the default replacement strategy, devices are deleted and created anew
+	ReplacementStrategyRecreate string = "recreate"
+	// ReplacementStrategyReinstall is the replacement strategy that re-images deleted reserved devices, parks them
+	// in the free pool of their project and reinstalls parked devices on creation
+	ReplacementStrategyReinstall string = "reinstall"
+
+	// AnnotationApproveDeletion is the Machine annotation that, when set to "true", approves unlocking a locked device
+	// for its deletion.
+	AnnotationApproveDeletion string = "equinixmetal.gardener.cloud/approve-deletion"
+
+	// AnnotationAllowUnknownFields is the MachineClass annotation that, when set to "true", decodes the provider spec
+	// leniently, ignoring unknown fields and matching keys case-insensitively.
+	AnnotationAllowUnknownFields string = "equinixmetal.gardener.cloud/allow-unknown-fields"
+
+	// AnnotationAllowUnknownCatalogValues is the MachineClass annotation that, when set to "true", accepts machine types
+	// and operating systems unknown to the plan catalog, e.g. ones released after the provider.
+	AnnotationAllowUnknownCatalogValues string = "equinixmetal.gardener.cloud/allow-unknown-catalog-values"
 )
 
 // EquinixMetalProviderSpec is the spec to be used while parsing the calls.
+// It is the internal representation that all versions of the provider spec are converted to.
 type EquinixMetalProviderSpec struct {
 	APIVersion     string   `json:"apiVersion,omitempty"`
 	Metro          string   `json:"metro,omitempty"`
@@ -28,4 +108,79 @@ type EquinixMetalProviderSpec struct {
 	UserData       string   `json:"userdata,omitempty"`
 	ReservationIDs []string `json:"reservationIDs,omitempty"`
 	ReservedOnly   bool     `json:"reservedDevicesOnly,omitempty"`
+	// Labels is a map-style alternative to Tags, each entry becomes a "key: value" device tag.
+	Labels map[string]string `json:"labels,omitempty"`
+	// FallbackMetros is an ordered list of metros that are tried when Metro has no capacity for the machine type.
+	FallbackMetros []string `json:"fallbackMetros,omitempty"`
+	// FallbackMachineTypes is an ordered list of plans that are tried when MachineType has no capacity.
+	FallbackMachineTypes []string `json:"fallbackMachineTypes,omitempty"`
+	// ProviderIDFormat selects the format of the ProviderIDs, one of ProviderIDFormatMetro (default), ProviderIDFormatProject,
+	// ProviderIDFormatCCM or ProviderIDFormatPacket.
+	ProviderIDFormat string `json:"providerIDFormat,omitempty"`
+	// DerivedTags configures which tags are derived from the Machine and MachineClass metadata
+	// and added to the static Tags on creation.
+	DerivedTags *DerivedTags `json:"derivedTags,omitempty"`
+	// ReplacementStrategy selects how devices are replaced, one of ReplacementStrategyRecreate (default) or
+	// ReplacementStrategyReinstall. Only devices with a hardware reservation are reinstalled.
+	ReplacementStrategy string `json:"replacementStrategy,omitempty"`
+	// Locked locks the devices on creation, so that they are only deleted for Machines carrying
+	// AnnotationApproveDeletion.
+	Locked bool `json:"locked,omitempty"`
+	// AlwaysPXE boots the devices with the iPXE script on every boot instead of only on provisioning.
+	AlwaysPXE bool `json:"alwaysPxe,omitempty"`
+	// IPXEScriptSecretKey is the key of the MachineClass secret containing an inline iPXE script, which is passed as
+	// userdata instead of the userData of the secret. It is an alternative to IPXEScriptURL.
+	IPXEScriptSecretKey string `json:"ipxeScriptSecretKey,omitempty"`
+	// SSHKeyLabels selects project SSH keys by their label in addition to the project SSH key IDs of SSHKeys.
+	SSHKeyLabels []string `json:"sshKeyLabels,omitempty"`
+	// UserSSHKeys are the IDs of the users whose SSH keys are injected.
+	UserSSHKeys []string `json:"userSshKeys,omitempty"`
+	// NoSSHKeys injects no SSH keys at all. Otherwise, Equinix Metal injects all project and user keys if no keys
+	// are selected.
+	NoSSHKeys bool `json:"noSshKeys,omitempty"`
+	// CustomData is a JSON object passed to the metadata service of the devices as customdata.
+	CustomData json.RawMessage `json:"customData,omitempty"`
+	// CustomDataSecretKey is the key of the MachineClass secret containing a JSON object that is merged into
+	// CustomData, so that sensitive parts are not part of the MachineClass.
+	CustomDataSecretKey string `json:"customDataSecretKey,omitempty"`
+	// Projects spreads the devices across several projects as an alternative to ProjectID. As project SSH keys
+	// belong to a single project, several projects select their keys with SSHKeyLabels instead of SSHKeys.
+	Projects []Project `json:"projects,omitempty"`
+	// ProjectPlacement selects how a project is chosen from Projects, one of ProjectPlacementOrdered (default) or
+	// ProjectPlacementWeighted. The other projects are tried if the creation in the chosen project fails.
+	ProjectPlacement string `json:"projectPlacement,omitempty"`
+}
+
+// Project is a project devices are placed in.
+type Project struct {
+	ID string `json:"id"`
+	// Weight is the relative share of the devices created in the project by the weighted project placement.
+	Weight int `json:"weight,omitempty"`
+}
+
+// ProjectIDs returns the IDs of the Projects, or ProjectID if there are none.
+func (s *EquinixMetalProviderSpec) ProjectIDs() []string {
+	if len(s.Projects) == 0 {
+		return []string{s.ProjectID}
+	}
+	ids := make([]string, 0, len(s.Projects))
+	for _, project := range s.Projects {
+		ids = append(ids, project.ID)
+	}
+	return ids
+}
+
+// MetrosAndPlans returns Metro and MachineType followed by their fallbacks, in order of preference.
+func (s *EquinixMetalProviderSpec) MetrosAndPlans() (metros, plans []string) {
+	metros = append([]string{s.Metro}, s.FallbackMetros...)
+	plans = append([]string{s.MachineType}, s.FallbackMachineTypes...)
+	return metros, plans
+}
+
+// DerivedTags selects the Machine and MachineClass metadata that is propagated into device tags.
+type DerivedTags struct {
+	MachineName   bool     `json:"machineName,omitempty"`
+	Namespace     bool     `json:"namespace,omitempty"`
+	MachineClass  bool     `json:"machineClass,omitempty"`
+	MachineLabels []string `json:"machineLabels,omitempty"`
 }
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// TagKeyPrefixCluster is the key prefix of the tag identifying the cluster a device belongs to
+	TagKeyPrefixCluster string = "kubernetes.io/cluster/"
+	// TagKeyPrefixRole is the key prefix of the tag identifying the role of a device in the cluster
+	TagKeyPrefixRole string = "kubernetes.io/role/"
+)
+
+// Tag is a structured device tag. Equinix Metal tags are plain strings, which are
+// written as "key: value", or just "key" if the tag has no value.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// ParseTag parses a tag in the "key: value", "key=value" or "key" form.
+func ParseTag(tag string) Tag {
+	sep := strings.IndexAny(tag, ":=")
+	if sep < 0 {
+		return Tag{Key: strings.TrimSpace(tag)}
+	}
+	return Tag{
+		Key:   strings.TrimSpace(tag[:sep]),
+		Value: strings.TrimSpace(tag[sep+1:]),
+	}
+}
+
+// ParseTags parses each of the given tags with ParseTag.
+func ParseTags(tags []string) []Tag {
+	parsed := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		parsed = append(parsed, ParseTag(tag))
+	}
+	return parsed
+}
+
+// String formats the tag in the "key: value" form used for device tags.
+func (t Tag) String() string {
+	if t.Value == "" {
+		return t.Key
+	}
+	return fmt.Sprintf("%s: %s", t.Key, t.Value)
+}
+
+// IsClusterTag returns true if the tag key is exactly prefixed with TagKeyPrefixCluster followed by a cluster name.
+func (t Tag) IsClusterTag() bool {
+	return hasKeyPrefix(t.Key, TagKeyPrefixCluster)
+}
+
+// IsRoleTag returns true if the tag key is exactly prefixed with TagKeyPrefixRole followed by a role name.
+func (t Tag) IsRoleTag() bool {
+	return hasKeyPrefix(t.Key, TagKeyPrefixRole)
+}
+
+func hasKeyPrefix(key, prefix string) bool {
+	return len(key) > len(prefix) && strings.HasPrefix(key, prefix)
+}
+
+// ClusterAndRoleTags returns the last cluster and role tags found in the given tags, or nil if there is none.
+func ClusterAndRoleTags(tags []Tag) (cluster, role *Tag) {
+	for i := range tags {
+		switch {
+		case tags[i].IsClusterTag():
+			cluster = &tags[i]
+		case tags[i].IsRoleTag():
+			role = &tags[i]
+		}
+	}
+	return cluster, role
+}
+
+// FormatTags formats each of the given tags with Tag.String.
+func FormatTags(tags []Tag) []string {
+	formatted := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		formatted = append(formatted, tag.String())
+	}
+	return formatted
+}
+
+// AllTags returns the structured tags of the spec, that are the parsed Tags followed by the Labels sorted by key.
+func (s *EquinixMetalProviderSpec) AllTags() []Tag {
+	tags := ParseTags(s.Tags)
+
+	keys := make([]string, 0, len(s.Labels))
+	for key := range s.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		tags = append(tags, Tag{Key: key, Value: s.Labels[key]})
+	}
+	return tags
+}
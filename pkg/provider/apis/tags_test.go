@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package api_test
+
+import (
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tags", func() {
+	DescribeTable("#ParseTag",
+		func(tag string, expected api.Tag, formatted string) {
+			parsed := api.ParseTag(tag)
+			Expect(parsed).To(Equal(expected))
+			Expect(parsed.String()).To(Equal(formatted))
+		},
+		Entry("colon separated", "kubernetes.io/cluster/shoot: 1", api.Tag{Key: "kubernetes.io/cluster/shoot", Value: "1"}, "kubernetes.io/cluster/shoot: 1"),
+		Entry("equals separated", "kubernetes.io/role/node=1", api.Tag{Key: "kubernetes.io/role/node", Value: "1"}, "kubernetes.io/role/node: 1"),
+		Entry("key only", "worker", api.Tag{Key: "worker"}, "worker"),
+		Entry("value with separator", "url: https://example.com", api.Tag{Key: "url", Value: "https://example.com"}, "url: https://example.com"),
+	)
+
+	DescribeTable("#ClusterAndRoleTags",
+		func(tags []string, cluster, role *api.Tag) {
+			clusterTag, roleTag := api.ClusterAndRoleTags(api.ParseTags(tags))
+			Expect(clusterTag).To(Equal(cluster))
+			Expect(roleTag).To(Equal(role))
+		},
+		Entry("both present", []string{"kubernetes.io/cluster/shoot: 1", "kubernetes.io/role/node: 1"},
+			&api.Tag{Key: "kubernetes.io/cluster/shoot", Value: "1"}, &api.Tag{Key: "kubernetes.io/role/node", Value: "1"}),
+		Entry("substring only", []string{"foo.kubernetes.io/cluster/shoot: 1", "x-kubernetes.io/role/node"}, nil, nil),
+		Entry("prefix without name", []string{"kubernetes.io/cluster/: 1", "kubernetes.io/role/"}, nil, nil),
+	)
+
+	Describe("#AllTags", func() {
+		It("should append the labels sorted by key", func() {
+			spec := &api.EquinixMetalProviderSpec{
+				Tags:   []string{"a: 1"},
+				Labels: map[string]string{"c": "3", "b": "2"},
+			}
+			Expect(api.FormatTags(spec.AllTags())).To(Equal([]string{"a: 1", "b: 2", "c: 3"}))
+		})
+	})
+})
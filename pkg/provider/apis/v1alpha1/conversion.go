@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+)
+
+// ConvertTo converts the spec to the internal provider spec.
+func (in *EquinixMetalProviderSpec) ConvertTo(out *api.EquinixMetalProviderSpec) {
+	*out = api.EquinixMetalProviderSpec{
+		APIVersion:           in.APIVersion,
+		Metro:                in.Metro,
+		MachineType:          in.MachineType,
+		BillingCycle:         in.BillingCycle,
+		OS:                   in.OS,
+		IPXEScriptURL:        in.IPXEScriptURL,
+		ProjectID:            in.ProjectID,
+		Tags:                 in.Tags,
+		SSHKeys:              in.SSHKeys,
+		UserData:             in.UserData,
+		ReservationIDs:       in.ReservationIDs,
+		ReservedOnly:         in.ReservedOnly,
+		Labels:               in.Labels,
+		FallbackMetros:       in.FallbackMetros,
+		FallbackMachineTypes: in.FallbackMachineTypes,
+		ProviderIDFormat:     in.ProviderIDFormat,
+	}
+	if in.DerivedTags != nil {
+		out.DerivedTags = &api.DerivedTags{
+			MachineName:   in.DerivedTags.MachineName,
+			Namespace:     in.DerivedTags.Namespace,
+			MachineClass:  in.DerivedTags.MachineClass,
+			MachineLabels: in.DerivedTags.MachineLabels,
+		}
+	}
+}
+
+// ConvertFrom converts the internal provider spec to this version, dropping the options only available in v1alpha2.
+func (in *EquinixMetalProviderSpec) ConvertFrom(from *api.EquinixMetalProviderSpec) {
+	*in = EquinixMetalProviderSpec{
+		APIVersion:           api.V1alpha1,
+		Metro:                from.Metro,
+		MachineType:          from.MachineType,
+		BillingCycle:         from.BillingCycle,
+		OS:                   from.OS,
+		IPXEScriptURL:        from.IPXEScriptURL,
+		ProjectID:            from.ProjectID,
+		Tags:                 from.Tags,
+		SSHKeys:              from.SSHKeys,
+		UserData:             from.UserData,
+		ReservationIDs:       from.ReservationIDs,
+		ReservedOnly:         from.ReservedOnly,
+		Labels:               from.Labels,
+		FallbackMetros:       from.FallbackMetros,
+		FallbackMachineTypes: from.FallbackMachineTypes,
+		ProviderIDFormat:     from.ProviderIDFormat,
+	}
+	if from.DerivedTags != nil {
+		in.DerivedTags = &DerivedTags{
+			MachineName:   from.DerivedTags.MachineName,
+			Namespace:     from.DerivedTags.Namespace,
+			MachineClass:  from.DerivedTags.MachineClass,
+			MachineLabels: from.DerivedTags.MachineLabels,
+		}
+	}
+}
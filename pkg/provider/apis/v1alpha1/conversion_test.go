@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1_test
+
+import (
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	. "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conversion", func() {
+	ipxeScriptURL := "https://example.com/boot.ipxe"
+	spec := &EquinixMetalProviderSpec{
+		APIVersion:           api.V1alpha1,
+		Metro:                "ny",
+		MachineType:          "c3.small.x86",
+		BillingCycle:         "hourly",
+		OS:                   "flatcar_stable",
+		IPXEScriptURL:        &ipxeScriptURL,
+		ProjectID:            "abcdefg",
+		Tags:                 []string{"kubernetes.io/cluster/shoot-test: 1"},
+		SSHKeys:              []string{"key-1"},
+		UserData:             "#!/bin/sh",
+		ReservationIDs:       []string{"reservation-1"},
+		ReservedOnly:         true,
+		Labels:               map[string]string{"kubernetes.io/role/test": "1"},
+		FallbackMetros:       []string{"da"},
+		FallbackMachineTypes: []string{"m3.small.x86"},
+		ProviderIDFormat:     api.ProviderIDFormatPacket,
+		DerivedTags:          &DerivedTags{MachineName: true, MachineLabels: []string{"name"}},
+	}
+
+	It("should round-trip through the internal provider spec", func() {
+		internal := &api.EquinixMetalProviderSpec{}
+		spec.ConvertTo(internal)
+		Expect(internal.Metro).To(Equal("ny"))
+		Expect(internal.DerivedTags).To(Equal(&api.DerivedTags{MachineName: true, MachineLabels: []string{"name"}}))
+
+		converted := &EquinixMetalProviderSpec{}
+		converted.ConvertFrom(internal)
+		Expect(converted).To(Equal(spec))
+	})
+
+	Describe("#SetDefaults", func() {
+		It("should only default the API version and ProviderID format", func() {
+			defaulted := &EquinixMetalProviderSpec{}
+			SetDefaults(defaulted)
+			Expect(defaulted).To(Equal(&EquinixMetalProviderSpec{
+				APIVersion:       api.V1alpha1,
+				ProviderIDFormat: api.ProviderIDFormatMetro,
+			}))
+		})
+	})
+})
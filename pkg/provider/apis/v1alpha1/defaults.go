@@ -0,0 +1,20 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+)
+
+// SetDefaults sets the defaults of unset fields. It keeps the behaviour of unversioned provider specs,
+// so apart from the API version and the ProviderID format, no fields are defaulted.
+func SetDefaults(spec *EquinixMetalProviderSpec) {
+	if spec.APIVersion == "" {
+		spec.APIVersion = api.V1alpha1
+	}
+	if spec.ProviderIDFormat == "" {
+		spec.ProviderIDFormat = api.ProviderIDFormatMetro
+	}
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package v1alpha1 contains the mcm.gardener.cloud/v1alpha1 version of the EquinixMetal provider spec
+package v1alpha1
+
+// EquinixMetalProviderSpec is the mcm.gardener.cloud/v1alpha1 provider spec with flat fields.
+// It is frozen, new options are only added to v1alpha2.
+type EquinixMetalProviderSpec struct {
+	APIVersion           string            `json:"apiVersion,omitempty"`
+	Metro                string            `json:"metro,omitempty"`
+	MachineType          string            `json:"machineType"`
+	BillingCycle         string            `json:"billingCycle"`
+	OS                   string            `json:"OS,omitempty"`
+	IPXEScriptURL        *string           `json:"ipxeScriptUrl,omitempty"`
+	ProjectID            string            `json:"projectID"`
+	Tags                 []string          `json:"tags,omitempty"`
+	SSHKeys              []string          `json:"sshKeys,omitempty"`
+	UserData             string            `json:"userdata,omitempty"`
+	ReservationIDs       []string          `json:"reservationIDs,omitempty"`
+	ReservedOnly         bool              `json:"reservedDevicesOnly,omitempty"`
+	Labels               map[string]string `json:"labels,omitempty"`
+	FallbackMetros       []string          `json:"fallbackMetros,omitempty"`
+	FallbackMachineTypes []string          `json:"fallbackMachineTypes,omitempty"`
+	ProviderIDFormat     string            `json:"providerIDFormat,omitempty"`
+	DerivedTags          *DerivedTags      `json:"derivedTags,omitempty"`
+}
+
+// DerivedTags selects the Machine and MachineClass metadata that is propagated into device tags.
+type DerivedTags struct {
+	MachineName   bool     `json:"machineName,omitempty"`
+	Namespace     bool     `json:"namespace,omitempty"`
+	MachineClass  bool     `json:"machineClass,omitempty"`
+	MachineLabels []string `json:"machineLabels,omitempty"`
+}
@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha2
+
+import (
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+)
+
+// ConvertTo converts the spec to the internal provider spec.
+// The first metro and machine type are the preferred ones, the remaining ones become the fallbacks.
+func (in *EquinixMetalProviderSpec) ConvertTo(out *api.EquinixMetalProviderSpec) {
+	*out = api.EquinixMetalProviderSpec{
+		APIVersion:          in.APIVersion,
+		BillingCycle:        in.Machine.BillingCycle,
+		OS:                  in.OperatingSystem.Slug,
+		IPXEScriptURL:       in.OperatingSystem.IPXEScriptURL,
+		ProjectID:           in.ProjectID,
+		Tags:                in.Tags,
+		SSHKeys:             in.SSHKeys,
+		SSHKeyLabels:        in.SSHKeyLabels,
+		UserSSHKeys:         in.UserSSHKeys,
+		NoSSHKeys:           in.NoSSHKeys,
+		CustomData:          in.CustomData,
+		CustomDataSecretKey: in.CustomDataSecretKey,
+		UserData:            in.UserData,
+		Labels:              in.Labels,
+		ProviderIDFormat:    in.ProviderIDFormat,
+		ReplacementStrategy: in.ReplacementStrategy,
+		Locked:              in.Locked,
+		AlwaysPXE:           in.OperatingSystem.AlwaysPXE,
+		IPXEScriptSecretKey: in.OperatingSystem.IPXEScriptSecretKey,
+	}
+	out.Metro, out.FallbackMetros = splitPreferred(in.Placement.Metros)
+	out.ProjectPlacement = in.Placement.ProjectPlacement
+	for _, project := range in.Placement.Projects {
+		out.Projects = append(out.Projects, api.Project{ID: project.ID, Weight: project.Weight})
+	}
+	out.MachineType, out.FallbackMachineTypes = splitPreferred(in.Machine.Types)
+	if in.Reservations != nil {
+		out.ReservationIDs = in.Reservations.IDs
+		out.ReservedOnly = in.Reservations.Only
+	}
+	if in.DerivedTags != nil {
+		out.DerivedTags = &api.DerivedTags{
+			MachineName:   in.DerivedTags.MachineName,
+			Namespace:     in.DerivedTags.Namespace,
+			MachineClass:  in.DerivedTags.MachineClass,
+			MachineLabels: in.DerivedTags.MachineLabels,
+		}
+	}
+}
+
+// ConvertFrom converts the internal provider spec to this version.
+func (in *EquinixMetalProviderSpec) ConvertFrom(from *api.EquinixMetalProviderSpec) {
+	*in = EquinixMetalProviderSpec{
+		APIVersion: api.V1alpha2,
+		ProjectID:  from.ProjectID,
+		Placement: Placement{
+			Metros:           joinPreferred(from.Metro, from.FallbackMetros),
+			ProjectPlacement: from.ProjectPlacement,
+		},
+		Machine: Machine{
+			Types:        joinPreferred(from.MachineType, from.FallbackMachineTypes),
+			BillingCycle: from.BillingCycle,
+		},
+		OperatingSystem: OperatingSystem{
+			Slug:                from.OS,
+			IPXEScriptURL:       from.IPXEScriptURL,
+			IPXEScriptSecretKey: from.IPXEScriptSecretKey,
+			AlwaysPXE:           from.AlwaysPXE,
+		},
+		Tags:                from.Tags,
+		Labels:              from.Labels,
+		SSHKeys:             from.SSHKeys,
+		SSHKeyLabels:        from.SSHKeyLabels,
+		UserSSHKeys:         from.UserSSHKeys,
+		NoSSHKeys:           from.NoSSHKeys,
+		CustomData:          from.CustomData,
+		CustomDataSecretKey: from.CustomDataSecretKey,
+		UserData:            from.UserData,
+		ProviderIDFormat:    from.ProviderIDFormat,
+		ReplacementStrategy: from.ReplacementStrategy,
+		Locked:              from.Locked,
+	}
+	for _, project := range from.Projects {
+		in.Placement.Projects = append(in.Placement.Projects, Project{ID: project.ID, Weight: project.Weight})
+	}
+	if len(from.ReservationIDs) > 0 || from.ReservedOnly {
+		in.Reservations = &Reservations{
+			IDs:  from.ReservationIDs,
+			Only: from.ReservedOnly,
+		}
+	}
+	if from.DerivedTags != nil {
+		in.DerivedTags = &DerivedTags{
+			MachineName:   from.DerivedTags.MachineName,
+			Namespace:     from.DerivedTags.Namespace,
+			MachineClass:  from.DerivedTags.MachineClass,
+			MachineLabels: from.DerivedTags.MachineLabels,
+		}
+	}
+}
+
+func splitPreferred(values []string) (preferred string, fallbacks []string) {
+	switch len(values) {
+	case 0:
+		return "", nil
+	case 1:
+		return values[0], nil
+	}
+	return values[0], values[1:]
+}
+
+func joinPreferred(preferred string, fallbacks []string) []string {
+	if preferred == "" && len(fallbacks) == 0 {
+		return nil
+	}
+	return append([]string{preferred}, fallbacks...)
+}
@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha2_test
+
+import (
+	"encoding/json"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	. "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/v1alpha2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Conversion", func() {
+	ipxeScriptURL := "https://example.com/boot.ipxe"
+
+	DescribeTable("#ConvertTo",
+		func(spec *EquinixMetalProviderSpec, expected *api.EquinixMetalProviderSpec) {
+			internal := &api.EquinixMetalProviderSpec{}
+			spec.ConvertTo(internal)
+			Expect(internal).To(Equal(expected))
+
+			converted := &EquinixMetalProviderSpec{}
+			converted.ConvertFrom(internal)
+			Expect(converted).To(Equal(spec))
+		},
+		Entry("minimal", &EquinixMetalProviderSpec{
+			APIVersion:      api.V1alpha2,
+			ProjectID:       "abcdefg",
+			Placement:       Placement{Metros: []string{"ny"}},
+			Machine:         Machine{Types: []string{"c3.small.x86"}, BillingCycle: "hourly"},
+			OperatingSystem: OperatingSystem{Slug: "flatcar_stable"},
+		}, &api.EquinixMetalProviderSpec{
+			APIVersion:   api.V1alpha2,
+			ProjectID:    "abcdefg",
+			Metro:        "ny",
+			MachineType:  "c3.small.x86",
+			BillingCycle: "hourly",
+			OS:           "flatcar_stable",
+		}),
+		Entry("projects", &EquinixMetalProviderSpec{
+			APIVersion: api.V1alpha2,
+			Placement: Placement{
+				Metros:           []string{"ny"},
+				Projects:         []Project{{ID: "abcdefg", Weight: 2}, {ID: "hijklmn", Weight: 1}},
+				ProjectPlacement: api.ProjectPlacementWeighted,
+			},
+			Machine:          Machine{Types: []string{"c3.small.x86"}, BillingCycle: "hourly"},
+			OperatingSystem:  OperatingSystem{Slug: "flatcar_stable"},
+			ProviderIDFormat: api.ProviderIDFormatProject,
+		}, &api.EquinixMetalProviderSpec{
+			APIVersion:       api.V1alpha2,
+			Metro:            "ny",
+			MachineType:      "c3.small.x86",
+			BillingCycle:     "hourly",
+			OS:               "flatcar_stable",
+			Projects:         []api.Project{{ID: "abcdefg", Weight: 2}, {ID: "hijklmn", Weight: 1}},
+			ProjectPlacement: api.ProjectPlacementWeighted,
+			ProviderIDFormat: api.ProviderIDFormatProject,
+		}),
+		Entry("all fields", &EquinixMetalProviderSpec{
+			APIVersion:          api.V1alpha2,
+			ProjectID:           "abcdefg",
+			Placement:           Placement{Metros: []string{"ny", "da"}},
+			Machine:             Machine{Types: []string{"c3.small.x86", "m3.small.x86"}, BillingCycle: "daily"},
+			OperatingSystem:     OperatingSystem{IPXEScriptURL: &ipxeScriptURL, AlwaysPXE: true},
+			Reservations:        &Reservations{IDs: []string{"reservation-1"}, Only: true},
+			Tags:                []string{"kubernetes.io/cluster/shoot-test: 1"},
+			Labels:              map[string]string{"kubernetes.io/role/test": "1"},
+			DerivedTags:         &DerivedTags{Namespace: true, MachineClass: true},
+			SSHKeys:             []string{"key-1"},
+			SSHKeyLabels:        []string{"ops"},
+			UserSSHKeys:         []string{"user-1"},
+			CustomData:          json.RawMessage(`{"role":"worker"}`),
+			CustomDataSecretKey: "customData",
+			UserData:            "#!/bin/sh",
+			ProviderIDFormat:    api.ProviderIDFormatPacket,
+			ReplacementStrategy: api.ReplacementStrategyReinstall,
+			Locked:              true,
+		}, &api.EquinixMetalProviderSpec{
+			APIVersion:           api.V1alpha2,
+			ProjectID:            "abcdefg",
+			Metro:                "ny",
+			FallbackMetros:       []string{"da"},
+			MachineType:          "c3.small.x86",
+			FallbackMachineTypes: []string{"m3.small.x86"},
+			BillingCycle:         "daily",
+			IPXEScriptURL:        &ipxeScriptURL,
+			ReservationIDs:       []string{"reservation-1"},
+			ReservedOnly:         true,
+			Tags:                 []string{"kubernetes.io/cluster/shoot-test: 1"},
+			Labels:               map[string]string{"kubernetes.io/role/test": "1"},
+			DerivedTags:          &api.DerivedTags{Namespace: true, MachineClass: true},
+			SSHKeys:              []string{"key-1"},
+			SSHKeyLabels:         []string{"ops"},
+			UserSSHKeys:          []string{"user-1"},
+			CustomData:           json.RawMessage(`{"role":"worker"}`),
+			CustomDataSecretKey:  "customData",
+			UserData:             "#!/bin/sh",
+			ProviderIDFormat:     api.ProviderIDFormatPacket,
+			ReplacementStrategy:  api.ReplacementStrategyReinstall,
+			Locked:               true,
+			AlwaysPXE:            true,
+		}),
+	)
+
+	Describe("#SetDefaults", func() {
+		It("should default the billing cycle and ProviderID format", func() {
+			defaulted := &EquinixMetalProviderSpec{}
+			SetDefaults(defaulted)
+			Expect(defaulted).To(Equal(&EquinixMetalProviderSpec{
+				APIVersion:       api.V1alpha2,
+				Machine:          Machine{BillingCycle: DefaultBillingCycle},
+				ProviderIDFormat: api.ProviderIDFormatMetro,
+			}))
+		})
+
+		// switching a MachineClass to projects must not change the ProviderIDs of its machines
+		It("should not default the project ProviderID format for specs with projects", func() {
+			defaulted := &EquinixMetalProviderSpec{Placement: Placement{Projects: []Project{{ID: "abcdefg"}}}}
+			SetDefaults(defaulted)
+			Expect(defaulted.ProviderIDFormat).To(Equal(api.ProviderIDFormatMetro))
+		})
+
+		It("should not overwrite set fields", func() {
+			spec := &EquinixMetalProviderSpec{
+				APIVersion:       api.V1alpha2,
+				Machine:          Machine{BillingCycle: "monthly"},
+				ProviderIDFormat: api.ProviderIDFormatPacket,
+			}
+			defaulted := *spec
+			SetDefaults(&defaulted)
+			Expect(&defaulted).To(Equal(spec))
+		})
+	})
+})
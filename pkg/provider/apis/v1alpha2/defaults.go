@@ -0,0 +1,25 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha2
+
+import (
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+)
+
+// DefaultBillingCycle is the billing cycle of devices if none is given
+const DefaultBillingCycle = "hourly"
+
+// SetDefaults sets the defaults of unset fields.
+func SetDefaults(spec *EquinixMetalProviderSpec) {
+	if spec.APIVersion == "" {
+		spec.APIVersion = api.V1alpha2
+	}
+	if spec.Machine.BillingCycle == "" {
+		spec.Machine.BillingCycle = DefaultBillingCycle
+	}
+	if spec.ProviderIDFormat == "" {
+		spec.ProviderIDFormat = api.ProviderIDFormatMetro
+	}
+}
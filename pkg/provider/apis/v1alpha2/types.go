@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package v1alpha2 contains the mcm.gardener.cloud/v1alpha2 version of the EquinixMetal provider spec
+package v1alpha2
+
+import "encoding/json"
+
+// EquinixMetalProviderSpec is the mcm.gardener.cloud/v1alpha2 provider spec, which groups the
+// placement, machine, operating system and reservation settings into structured fields.
+type EquinixMetalProviderSpec struct {
+	APIVersion          string            `json:"apiVersion"`
+	ProjectID           string            `json:"projectID"`
+	Placement           Placement         `json:"placement"`
+	Machine             Machine           `json:"machine"`
+	OperatingSystem     OperatingSystem   `json:"operatingSystem"`
+	Reservations        *Reservations     `json:"reservations,omitempty"`
+	Tags                []string          `json:"tags,omitempty"`
+	Labels              map[string]string `json:"labels,omitempty"`
+	DerivedTags         *DerivedTags      `json:"derivedTags,omitempty"`
+	SSHKeys             []string          `json:"sshKeys,omitempty"`
+	SSHKeyLabels        []string          `json:"sshKeyLabels,omitempty"`
+	UserSSHKeys         []string          `json:"userSshKeys,omitempty"`
+	NoSSHKeys           bool              `json:"noSshKeys,omitempty"`
+	CustomData          json.RawMessage   `json:"customData,omitempty"`
+	CustomDataSecretKey string            `json:"customDataSecretKey,omitempty"`
+	UserData            string            `json:"userdata,omitempty"`
+	ProviderIDFormat    string            `json:"providerIDFormat,omitempty"`
+	ReplacementStrategy string            `json:"replacementStrategy,omitempty"`
+	Locked              bool              `json:"locked,omitempty"`
+}
+
+// Placement defines where devices are provisioned.
+type Placement struct {
+	// Metros is the ordered list of metros, the first one is preferred and the others are fallbacks.
+	Metros []string `json:"metros"`
+	// Projects spreads the devices across several projects as an alternative to the projectID.
+	Projects []Project `json:"projects,omitempty"`
+	// ProjectPlacement selects how a project is chosen from Projects, "ordered" (default) or "weighted".
+	ProjectPlacement string `json:"projectPlacement,omitempty"`
+}
+
+// Project is a project devices are placed in.
+type Project struct {
+	ID string `json:"id"`
+	// Weight is the relative share of the devices created in the project by the weighted project placement.
+	Weight int `json:"weight,omitempty"`
+}
+
+// Machine defines the hardware and billing of devices.
+type Machine struct {
+	// Types is the ordered list of plans, the first one is preferred and the others are fallbacks.
+	Types        []string `json:"types"`
+	BillingCycle string   `json:"billingCycle,omitempty"`
+}
+
+// OperatingSystem defines what devices boot, either an operating system slug or a custom iPXE script.
+type OperatingSystem struct {
+	Slug          string  `json:"slug,omitempty"`
+	IPXEScriptURL *string `json:"ipxeScriptUrl,omitempty"`
+	// IPXEScriptSecretKey is the key of the MachineClass secret containing an inline iPXE script.
+	IPXEScriptSecretKey string `json:"ipxeScriptSecretKey,omitempty"`
+	// AlwaysPXE boots the iPXE script on every boot instead of only on provisioning.
+	AlwaysPXE bool `json:"alwaysPxe,omitempty"`
+}
+
+// Reservations defines the hardware reservations devices are provisioned from.
+type Reservations struct {
+	IDs []string `json:"ids,omitempty"`
+	// Only forbids falling back to on-demand devices if none of the reservations is available.
+	Only bool `json:"only,omitempty"`
+}
+
+// DerivedTags selects the Machine and MachineClass metadata that is propagated into device tags.
+type DerivedTags struct {
+	MachineName   bool     `json:"machineName,omitempty"`
+	Namespace     bool     `json:"namespace,omitempty"`
+	MachineClass  bool     `json:"machineClass,omitempty"`
+	MachineLabels []string `json:"machineLabels,omitempty"`
+}
@@ -7,12 +7,15 @@
 package validation
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 
 	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/catalog"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
@@ -20,6 +23,12 @@ import (
 const (
 	nameFmt       string = `[-a-z0-9]+`
 	nameMaxLength int    = 63
+	tagFmt        string = `[-a-zA-Z0-9_.:/=@+ ]+`
+	tagMaxLength  int    = 255
+	// tagsMaxLength is the maximum combined length of all tags of a device
+	tagsMaxLength int = 4096
+	// CustomDataMaxLength is the maximum length of the JSON encoded customdata of a device
+	CustomDataMaxLength int = 64 * 1024
 	// SecretFieldAPIKey is the field name containing the API token
 	SecretFieldAPIKey = "apiToken"
 	// SecretFieldUserData is the field name containing the userData for the VM
@@ -28,6 +37,8 @@ const (
 
 var (
 	nameRegexp          = regexp.MustCompile("^" + nameFmt + "$")
+	uuidRegexp          = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+	tagRegexp           = regexp.MustCompile("^" + tagFmt + "$")
 	secretFieldDefaults = []string{SecretFieldAPIKey, SecretFieldUserData}
 )
 
@@ -38,24 +49,212 @@ func ValidateProviderSpec(spec *api.EquinixMetalProviderSpec, fldPath *field.Pat
 		allErrs = field.ErrorList{}
 	)
 
-	if "" == spec.OS && nil == spec.IPXEScriptURL {
-		allErrs = append(allErrs, field.Required(fldPath.Child("os"), "OS or IPXEScriptURL is required"))
+	if "" == spec.OS && nil == spec.IPXEScriptURL && "" == spec.IPXEScriptSecretKey {
+		allErrs = append(allErrs, field.Required(fldPath.Child("os"), "OS, IPXEScriptURL or IPXEScriptSecretKey is required"))
+	}
+	allErrs = append(allErrs, validateIPXE(spec, fldPath)...)
+	allErrs = append(allErrs, validateSSHKeys(spec, fldPath)...)
+	if len(spec.CustomData) > 0 {
+		allErrs = append(allErrs, ValidateCustomData(spec.CustomData, fldPath.Child("customData"))...)
 	}
 	if "" == spec.MachineType {
 		allErrs = append(allErrs, field.Required(fldPath.Child("machineType"), "Machine Type is required"))
 	}
-	if "" == spec.ProjectID {
+	if "" == spec.ProjectID && len(spec.Projects) == 0 {
 		allErrs = append(allErrs, field.Required(fldPath.Child("projectID"), "Project ID is required"))
 	}
+	allErrs = append(allErrs, validateProjects(spec, fldPath)...)
 	if "" == spec.Metro {
 		allErrs = append(allErrs, field.Required(fldPath.Child("metro"), "Metro is required"))
 	}
 
-	allErrs = append(allErrs, validateTags(spec.Tags, field.NewPath("spec.tags"))...)
+	for i, metro := range spec.FallbackMetros {
+		if metro == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("fallbackMetros").Index(i), "Metro must not be empty"))
+		}
+	}
+	for i, machineType := range spec.FallbackMachineTypes {
+		if machineType == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("fallbackMachineTypes").Index(i), "Machine Type must not be empty"))
+		}
+	}
+	if "" == spec.BillingCycle {
+		allErrs = append(allErrs, field.Required(fldPath.Child("billingCycle"), "Billing Cycle is required"))
+	} else if known := catalog.Embedded(); !known.HasBillingCycle(spec.BillingCycle) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("billingCycle"), spec.BillingCycle, known.BillingCycles))
+	}
+	switch spec.ProviderIDFormat {
+	case "", api.ProviderIDFormatMetro, api.ProviderIDFormatProject, api.ProviderIDFormatCCM, api.ProviderIDFormatPacket:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("providerIDFormat"), spec.ProviderIDFormat,
+			[]string{api.ProviderIDFormatMetro, api.ProviderIDFormatProject, api.ProviderIDFormatCCM, api.ProviderIDFormatPacket}))
+	}
+	switch spec.ReplacementStrategy {
+	case "", api.ReplacementStrategyRecreate, api.ReplacementStrategyReinstall:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("replacementStrategy"), spec.ReplacementStrategy,
+			[]string{api.ReplacementStrategyRecreate, api.ReplacementStrategyReinstall}))
+	}
+
+	allErrs = append(allErrs, validateTags(spec.AllTags(), field.NewPath("spec.tags"))...)
+	allErrs = append(allErrs, ValidateDeviceTags(spec.Tags, fldPath.Child("tags"))...)
+	allErrs = append(allErrs, ValidateDeviceTagsLength(api.FormatTags(spec.AllTags()), fldPath.Child("tags"))...)
+	for key, value := range spec.Labels {
+		if key == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("labels"), "Label key must not be empty"))
+			continue
+		}
+		allErrs = append(allErrs, validateDeviceTag(api.Tag{Key: key, Value: value}.String(), fldPath.Child("labels").Key(key))...)
+	}
+	if spec.DerivedTags != nil {
+		for i, label := range spec.DerivedTags.MachineLabels {
+			if label == "" {
+				allErrs = append(allErrs, field.Required(fldPath.Child("derivedTags", "machineLabels").Index(i), "Label key must not be empty"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateCatalog validates the plans and the operating system against the catalog of known values
+func ValidateCatalog(spec *api.EquinixMetalProviderSpec, known *catalog.Catalog, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.MachineType != "" && !known.HasPlan(spec.MachineType) {
+		allErrs = append(allErrs, unknownValue(fldPath.Child("machineType"), spec.MachineType, "machine type", known.Plans))
+	}
+	for i, machineType := range spec.FallbackMachineTypes {
+		if machineType != "" && !known.HasPlan(machineType) {
+			allErrs = append(allErrs, unknownValue(fldPath.Child("fallbackMachineTypes").Index(i), machineType, "machine type", known.Plans))
+		}
+	}
+	// the operating system may also be given by its ID
+	if spec.OS != "" && !uuidRegexp.MatchString(spec.OS) && !known.HasOperatingSystem(spec.OS) {
+		allErrs = append(allErrs, unknownValue(fldPath.Child("OS"), spec.OS, "operating system", known.OperatingSystems))
+	}
+
+	return allErrs
+}
+
+// validateProjects validates that the projects are given either by projectID or by projects, that the projects are
+// unique and that the weighted project placement has a project with a positive weight
+func validateProjects(spec *api.EquinixMetalProviderSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(spec.Projects) > 0 && spec.ProjectID != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("projects"), "must not be set together with projectID"))
+	}
+	var (
+		seen        = make(map[string]bool)
+		totalWeight int
+	)
+	for i, project := range spec.Projects {
+		idxPath := fldPath.Child("projects").Index(i)
+		if project.ID == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("id"), "Project ID is required"))
+		} else if seen[project.ID] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("id"), project.ID))
+		}
+		seen[project.ID] = true
+		if project.Weight < 0 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("weight"), project.Weight, "must not be negative"))
+		}
+		totalWeight += project.Weight
+	}
+	switch spec.ProjectPlacement {
+	case "", api.ProjectPlacementOrdered:
+	case api.ProjectPlacementWeighted:
+		if totalWeight <= 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("projects"), "a project with a positive weight is required for the weighted project placement"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("projectPlacement"), spec.ProjectPlacement,
+			[]string{api.ProjectPlacementOrdered, api.ProjectPlacementWeighted}))
+	}
+
+	return allErrs
+}
+
+// validateIPXE validates that at most one iPXE script source is given, that the script URL is an absolute https URL
+// and that alwaysPxe is only set for devices booting an iPXE script
+func validateIPXE(spec *api.EquinixMetalProviderSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.IPXEScriptURL != nil {
+		if u, err := url.Parse(*spec.IPXEScriptURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("ipxeScriptUrl"), *spec.IPXEScriptURL, "must be an absolute https URL"))
+		}
+		if spec.IPXEScriptSecretKey != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("ipxeScriptSecretKey"), "must not be set together with ipxeScriptUrl"))
+		}
+	}
+	if spec.AlwaysPXE && spec.IPXEScriptURL == nil && spec.IPXEScriptSecretKey == "" && spec.OS != api.OSCustomIPXE {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("alwaysPxe"), "requires an iPXE script"))
+	}
+
+	return allErrs
+}
+
+// validateSSHKeys validates that the SSH key labels are not empty, that the user SSH keys are user IDs and that no
+// keys are selected if SSH keys are disabled
+func validateSSHKeys(spec *api.EquinixMetalProviderSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, label := range spec.SSHKeyLabels {
+		if label == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("sshKeyLabels").Index(i), "SSH key label must not be empty"))
+		}
+	}
+	for i, id := range spec.UserSSHKeys {
+		if !uuidRegexp.MatchString(id) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("userSshKeys").Index(i), id, "must be a user ID"))
+		}
+	}
+	if spec.NoSSHKeys && (len(spec.SSHKeys) > 0 || len(spec.SSHKeyLabels) > 0 || len(spec.UserSSHKeys) > 0) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("noSshKeys"), "must not be set together with sshKeys, sshKeyLabels or userSshKeys"))
+	}
+	if len(spec.SSHKeys) > 0 && len(spec.Projects) > 1 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("sshKeys"), "project SSH key IDs must not be set together with several projects, use sshKeyLabels instead"))
+	}
+
+	return allErrs
+}
+
+// ValidateCustomData validates that the customdata is a JSON object that does not exceed CustomDataMaxLength and
+// does not set the topology key reserved for the provider. The value is not included in the errors, as it may be sensitive.
+func ValidateCustomData(data []byte, fldPath *field.Path) field.ErrorList {
+	allErrs := ValidateCustomDataLength(data, fldPath)
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil || object == nil {
+		allErrs = append(allErrs, field.Invalid(fldPath, "", "must be a JSON object"))
+	} else if _, ok := object[api.CustomDataKeyTopology]; ok {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child(api.CustomDataKeyTopology), "is reserved for the topology of the device"))
+	}
+
+	return allErrs
+}
+
+// ValidateCustomDataLength validates that the JSON encoded customdata does not exceed CustomDataMaxLength
+func ValidateCustomDataLength(data []byte, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(data) > CustomDataMaxLength {
+		allErrs = append(allErrs, field.TooLong(fldPath, "", CustomDataMaxLength))
+	}
 
 	return allErrs
 }
 
+func unknownValue(fldPath *field.Path, value, kind string, known []string) *field.Error {
+	msg := fmt.Sprintf("unknown %s", kind)
+	if suggestions := catalog.Suggest(value, known); len(suggestions) > 0 {
+		msg = fmt.Sprintf("%s, did you mean one of %v", msg, suggestions)
+	}
+	return field.Invalid(fldPath, value, msg)
+}
+
 // ValidateName validate that a name is valid
 func ValidateName(name string) []error {
 	var (
@@ -74,24 +273,74 @@ func ValidateName(name string) []error {
 	return errs
 }
 
-func validateTags(tags []string, fldPath *field.Path) field.ErrorList {
+func validateTags(tags []api.Tag, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
-	clusterName := ""
-	nodeRole := ""
-
-	for _, key := range tags {
-		if strings.Contains(key, "kubernetes.io/cluster/") {
-			clusterName = key
-		} else if strings.Contains(key, "kubernetes.io/role/") {
-			nodeRole = key
-		}
+
+	clusterTag, roleTag := api.ClusterAndRoleTags(tags)
+	if clusterTag == nil {
+		allErrs = append(allErrs, field.Required(fldPath.Child(api.TagKeyPrefixCluster), "Tag required of the form kubernetes.io/cluster/****"))
+	}
+	if roleTag == nil {
+		allErrs = append(allErrs, field.Required(fldPath.Child(api.TagKeyPrefixRole), "Tag required of the form kubernetes.io/role/****"))
 	}
 
-	if clusterName == "" {
-		allErrs = append(allErrs, field.Required(fldPath.Child("kubernetes.io/cluster/"), "Tag required of the form kubernetes.io/cluster/****"))
+	return allErrs
+}
+
+// ValidateDeviceTags validates that every tag only uses the allowed character set and does not exceed the maximum length
+func ValidateDeviceTags(tags []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, tag := range tags {
+		allErrs = append(allErrs, validateDeviceTag(tag, fldPath.Index(i))...)
+	}
+
+	return allErrs
+}
+
+// ValidateDeviceTagsLength validates that the combined length of the tags does not exceed the maximum length
+func ValidateDeviceTagsLength(tags []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	length := 0
+	for _, tag := range tags {
+		length += len(tag)
+	}
+	if length > tagsMaxLength {
+		allErrs = append(allErrs, field.TooLong(fldPath, "", tagsMaxLength))
+	}
+
+	return allErrs
+}
+
+func validateDeviceTag(tag string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(tag) > tagMaxLength {
+		allErrs = append(allErrs, field.TooLong(fldPath, tag, tagMaxLength))
+	}
+	if !tagRegexp.MatchString(tag) {
+		allErrs = append(allErrs, field.Invalid(fldPath, tag, fmt.Sprintf("tag did not match allowed regex '%v'", tagRegexp)))
+	}
+
+	return allErrs
+}
+
+// ValidateIPXEScript makes sure that the supplied secret contains an inline iPXE script in the given key
+func ValidateIPXEScript(secret *corev1.Secret, key string) field.ErrorList {
+	var (
+		allErrs = field.ErrorList{}
+		fldPath = field.NewPath("secretRef").Child(key)
+	)
+
+	if secret == nil {
+		return append(allErrs, field.Required(field.NewPath("secretRef"), "secretRef is required"))
 	}
-	if nodeRole == "" {
-		allErrs = append(allErrs, field.Required(fldPath.Child("kubernetes.io/role/"), "Tag required of the form kubernetes.io/role/****"))
+	script := string(secret.Data[key])
+	if script == "" {
+		allErrs = append(allErrs, field.Required(fldPath, "Required inline iPXE script"))
+	} else if !strings.HasPrefix(script, "#!ipxe") {
+		allErrs = append(allErrs, field.Invalid(fldPath, "", "iPXE script must start with #!ipxe"))
 	}
 
 	return allErrs
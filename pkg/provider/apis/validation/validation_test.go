@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/catalog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var _ = Describe("Validation", func() {
+	ptr := func(s string) *string { return &s }
+	newSpec := func(modify func(spec *api.EquinixMetalProviderSpec)) *api.EquinixMetalProviderSpec {
+		spec := &api.EquinixMetalProviderSpec{
+			Metro:        "ny",
+			MachineType:  "c3.small.x86",
+			BillingCycle: "hourly",
+			OS:           "ubuntu_22_04",
+			ProjectID:    "abcdefg",
+			Tags: []string{
+				"kubernetes.io/cluster/shoot-test: 1",
+				"kubernetes.io/role/test: 1",
+			},
+		}
+		if modify != nil {
+			modify(spec)
+		}
+		return spec
+	}
+
+	DescribeTable("#ValidateProviderSpec",
+		func(spec *api.EquinixMetalProviderSpec, expected []string) {
+			errs := ValidateProviderSpec(spec, field.NewPath("providerSpec"))
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			Expect(messages).To(Equal(expected))
+		},
+		Entry("valid", newSpec(nil), nil),
+		Entry("unknown machine type", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.MachineType = "huge"
+		}), nil),
+		Entry("missing billing cycle", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.BillingCycle = ""
+		}), []string{`providerSpec.billingCycle: Required value: Billing Cycle is required`}),
+		Entry("unsupported billing cycle", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.BillingCycle = "weekly"
+		}), []string{`providerSpec.billingCycle: Unsupported value: "weekly": supported values: "hourly", "daily", "monthly", "yearly"`}),
+		Entry("reinstall replacement strategy", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ReplacementStrategy = api.ReplacementStrategyReinstall
+		}), nil),
+		Entry("iPXE script URL with OS", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.IPXEScriptURL = ptr("https://example.com/boot.ipxe")
+		}), nil),
+		Entry("iPXE script URL without OS", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = ""
+			spec.IPXEScriptURL = ptr("https://example.com/boot.ipxe")
+			spec.AlwaysPXE = true
+		}), nil),
+		Entry("inline iPXE script without OS", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = ""
+			spec.IPXEScriptSecretKey = "ipxeScript"
+			spec.AlwaysPXE = true
+		}), nil),
+		Entry("always PXE with custom iPXE OS", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = api.OSCustomIPXE
+			spec.AlwaysPXE = true
+		}), nil),
+		Entry("neither OS nor iPXE script", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = ""
+		}), []string{`providerSpec.os: Required value: OS, IPXEScriptURL or IPXEScriptSecretKey is required`}),
+		Entry("plain http iPXE script URL", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.IPXEScriptURL = ptr("http://example.com/boot.ipxe")
+		}), []string{`providerSpec.ipxeScriptUrl: Invalid value: "http://example.com/boot.ipxe": must be an absolute https URL`}),
+		Entry("relative iPXE script URL", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.IPXEScriptURL = ptr("boot.ipxe")
+		}), []string{`providerSpec.ipxeScriptUrl: Invalid value: "boot.ipxe": must be an absolute https URL`}),
+		Entry("iPXE script URL and inline script", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.IPXEScriptURL = ptr("https://example.com/boot.ipxe")
+			spec.IPXEScriptSecretKey = "ipxeScript"
+		}), []string{`providerSpec.ipxeScriptSecretKey: Forbidden: must not be set together with ipxeScriptUrl`}),
+		Entry("always PXE without iPXE script", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.AlwaysPXE = true
+		}), []string{`providerSpec.alwaysPxe: Forbidden: requires an iPXE script`}),
+		Entry("SSH key selection", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.SSHKeys = []string{"8e9a2f07-4ab4-4bd5-9d51-0a40ea16bff5"}
+			spec.SSHKeyLabels = []string{"ops"}
+			spec.UserSSHKeys = []string{"b3d0f9a4-61c2-4d0e-9d6c-2f3c1a7e5b90"}
+		}), nil),
+		Entry("no SSH keys", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.NoSSHKeys = true
+		}), nil),
+		Entry("empty SSH key label", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.SSHKeyLabels = []string{""}
+		}), []string{`providerSpec.sshKeyLabels[0]: Required value: SSH key label must not be empty`}),
+		Entry("user SSH key without user ID", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.UserSSHKeys = []string{"alice"}
+		}), []string{`providerSpec.userSshKeys[0]: Invalid value: "alice": must be a user ID`}),
+		Entry("no SSH keys with selected keys", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.NoSSHKeys = true
+			spec.SSHKeyLabels = []string{"ops"}
+		}), []string{`providerSpec.noSshKeys: Forbidden: must not be set together with sshKeys, sshKeyLabels or userSshKeys`}),
+		Entry("custom data", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.CustomData = []byte(`{"role":"worker","labels":{"zone":"a"}}`)
+		}), nil),
+		Entry("custom data array", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.CustomData = []byte(`["worker"]`)
+		}), []string{`providerSpec.customData: Invalid value: "": must be a JSON object`}),
+		Entry("custom data null", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.CustomData = []byte(`null`)
+		}), []string{`providerSpec.customData: Invalid value: "": must be a JSON object`}),
+		Entry("custom data with topology", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.CustomData = []byte(`{"topology":{"metro":"ams"}}`)
+		}), []string{`providerSpec.customData.topology: Forbidden: is reserved for the topology of the device`}),
+		Entry("custom data too long", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.CustomData = []byte(`{"padding":"` + strings.Repeat("x", CustomDataMaxLength) + `"}`)
+		}), []string{`providerSpec.customData: Too long: must have at most 65536 bytes`}),
+		Entry("tags too long", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.Labels = map[string]string{}
+			for i := 0; i < 20; i++ {
+				spec.Labels[fmt.Sprintf("label-%d", i)] = strings.Repeat("x", 240)
+			}
+		}), []string{`providerSpec.tags: Too long: must have at most 4096 bytes`}),
+		Entry("ccm provider ID format", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProviderIDFormat = api.ProviderIDFormatCCM
+		}), nil),
+		Entry("unsupported provider ID format", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProviderIDFormat = "aws"
+		}), []string{`providerSpec.providerIDFormat: Unsupported value: "aws": supported values: "metro", "project", "ccm", "packet"`}),
+		Entry("projects", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProjectID = ""
+			spec.Projects = []api.Project{{ID: "abcdefg", Weight: 1}, {ID: "hijklmn"}}
+			spec.ProjectPlacement = api.ProjectPlacementWeighted
+			spec.ProviderIDFormat = api.ProviderIDFormatProject
+		}), nil),
+		Entry("SSH keys with projects", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProjectID = ""
+			spec.Projects = []api.Project{{ID: "abcdefg"}, {ID: "hijklmn"}}
+			spec.SSHKeys = []string{"key-1"}
+		}), []string{`providerSpec.sshKeys: Forbidden: project SSH key IDs must not be set together with several projects, use sshKeyLabels instead`}),
+		Entry("missing project", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProjectID = ""
+		}), []string{`providerSpec.projectID: Required value: Project ID is required`}),
+		Entry("project ID and projects", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.Projects = []api.Project{{ID: "hijklmn"}}
+		}), []string{`providerSpec.projects: Forbidden: must not be set together with projectID`}),
+		Entry("invalid projects", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProjectID = ""
+			spec.Projects = []api.Project{{ID: "abcdefg"}, {ID: "abcdefg"}, {Weight: -1}}
+		}), []string{
+			`providerSpec.projects[1].id: Duplicate value: "abcdefg"`,
+			`providerSpec.projects[2].id: Required value: Project ID is required`,
+			`providerSpec.projects[2].weight: Invalid value: -1: must not be negative`,
+		}),
+		Entry("weighted projects without weight", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProjectID = ""
+			spec.Projects = []api.Project{{ID: "abcdefg"}}
+			spec.ProjectPlacement = api.ProjectPlacementWeighted
+		}), []string{`providerSpec.projects: Required value: a project with a positive weight is required for the weighted project placement`}),
+		Entry("unsupported project placement", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProjectPlacement = "random"
+		}), []string{`providerSpec.projectPlacement: Unsupported value: "random": supported values: "ordered", "weighted"`}),
+		Entry("unsupported replacement strategy", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ReplacementStrategy = "replace"
+		}), []string{`providerSpec.replacementStrategy: Unsupported value: "replace": supported values: "recreate", "reinstall"`}),
+	)
+
+	DescribeTable("#ValidateCatalog",
+		func(spec *api.EquinixMetalProviderSpec, known *catalog.Catalog, expected []string) {
+			errs := ValidateCatalog(spec, known, field.NewPath("providerSpec"))
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			Expect(messages).To(Equal(expected))
+		},
+		Entry("valid", newSpec(nil), catalog.Embedded(), nil),
+		Entry("operating system ID", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = "8e9a2f07-4ab4-4bd5-9d51-0a40ea16bff5"
+		}), catalog.Embedded(), nil),
+		Entry("unknown operating system", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = "ubuntu_22_4"
+		}), catalog.Embedded(), []string{`providerSpec.OS: Invalid value: "ubuntu_22_4": unknown operating system, did you mean one of [ubuntu_22_04 ubuntu_20_04 ubuntu_24_04]`}),
+		Entry("unknown machine type without suggestion", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.MachineType = "huge"
+		}), catalog.Embedded(), []string{`providerSpec.machineType: Invalid value: "huge": unknown machine type`}),
+		Entry("unknown fallback machine type", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.FallbackMachineTypes = []string{"m3.smal.x86"}
+		}), catalog.Embedded(), []string{`providerSpec.fallbackMachineTypes[0]: Invalid value: "m3.smal.x86": unknown machine type, did you mean one of [m3.small.x86 c3.small.x86 t3.small.x86]`}),
+		Entry("machine type of merged catalog", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.MachineType = "x9.large.x86"
+		}), catalog.Embedded().Merge([]string{"x9.large.x86"}, nil), nil),
+	)
+
+	DescribeTable("#ValidateIPXEScript",
+		func(data map[string][]byte, expected []string) {
+			errs := ValidateIPXEScript(&corev1.Secret{Data: data}, "ipxeScript")
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			Expect(messages).To(Equal(expected))
+		},
+		Entry("valid", map[string][]byte{"ipxeScript": []byte("#!ipxe\nchain https://example.com/boot")}, nil),
+		Entry("missing", map[string][]byte{"userData": []byte("#!ipxe")},
+			[]string{`secretRef.ipxeScript: Required value: Required inline iPXE script`}),
+		Entry("no iPXE script", map[string][]byte{"ipxeScript": []byte("#!/bin/sh")},
+			[]string{`secretRef.ipxeScript: Invalid value: "": iPXE script must start with #!ipxe`}),
+	)
+})
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+)
+
+// selectMetroAndPlan checks the capacity of all metro and plan combinations of the provider spec
+// and returns the first combination that is able to fulfill a new device. All plans are tried in
+// a metro before falling back to the next metro.
+// If the capacity check itself fails, the primary metro and plan are returned, so that the
+// create call decides about the availability.
+func selectMetroAndPlan(
+	ctx context.Context,
+	svc spi.MetalDeviceService,
+	providerSpec *api.EquinixMetalProviderSpec,
+) (metro, plan string, err error) {
+	metros, plans := providerSpec.MetrosAndPlans()
+
+	var servers []metalv1.ServerInfo
+	for i := range metros {
+		for j := range plans {
+			servers = append(servers, metalv1.ServerInfo{
+				Metro:    &metros[i],
+				Plan:     &plans[j],
+				Quantity: metalv1.PtrString("1"),
+			})
+		}
+	}
+
+	capacity, _, err := svc.CheckCapacity(ctx, servers)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Could not check capacity, continuing with primary metro and plan",
+			logging.KeyMetro, providerSpec.Metro, logging.KeyPlan, providerSpec.MachineType)
+		return providerSpec.Metro, providerSpec.MachineType, nil
+	}
+
+	available := make(map[string]bool)
+	for _, s := range capacity.Servers {
+		if s.GetAvailable() {
+			available[s.GetMetro()+"/"+s.GetPlan()] = true
+		}
+	}
+	for _, m := range metros {
+		for _, p := range plans {
+			if available[m+"/"+p] {
+				klog.FromContext(ctx).V(3).Info("Selected metro and plan with available capacity", logging.KeyMetro, m, logging.KeyPlan, p)
+				return m, p, nil
+			}
+		}
+	}
+
+	return "", "", status.Error(codes.ResourceExhausted,
+		fmt.Sprintf("No capacity available for any of the plans %v in any of the metros %v", plans, metros))
+}
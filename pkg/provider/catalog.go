@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/catalog"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/validation"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// catalogRetryMinBackoff is the delay before retrying a failed catalog refresh, doubled on every further failure
+	catalogRetryMinBackoff = 10 * time.Second
+	// catalogRetryMaxBackoff caps the delay before retrying a failed catalog refresh
+	catalogRetryMaxBackoff = 10 * time.Minute
+)
+
+// refreshCatalog merges the plans and operating systems known to the Equinix Metal API into the catalog used
+// for validating provider specs. It only refreshes if enabled, the secret carries credentials and the next
+// refresh is due. Failures are logged only, the catalog then stays unchanged and the refresh is retried with
+// an exponential backoff.
+func (p *Provider) refreshCatalog(ctx context.Context, secret *corev1.Secret) {
+	if p.catalogRefreshInterval == 0 {
+		return
+	}
+	if err := validateSecretAPIKey(secret); err != nil {
+		return
+	}
+	p.mu.Lock()
+	if time.Now().Before(p.nextCatalogRefresh) {
+		p.mu.Unlock()
+		return
+	}
+	// concurrent requests must not refresh as well while this refresh is running
+	p.nextCatalogRefresh = time.Now().Add(p.catalogRefreshInterval)
+	p.mu.Unlock()
+
+	logger := klog.FromContext(ctx)
+	plans, operatingSystems, err := p.listCatalog(ctx, secret)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		backoff := catalogRetryMinBackoff << p.catalogRefreshFailures
+		if backoff > catalogRetryMaxBackoff || backoff <= 0 {
+			backoff = catalogRetryMaxBackoff
+		}
+		if backoff < p.catalogRefreshInterval {
+			p.nextCatalogRefresh = time.Now().Add(backoff)
+		}
+		p.catalogRefreshFailures++
+		logger.Error(err, "Could not refresh plan catalog", "retryAfter", time.Until(p.nextCatalogRefresh).Round(time.Second))
+		return
+	}
+	p.catalog = p.catalog.Merge(plans, operatingSystems)
+	p.catalogRefreshFailures = 0
+	logger.V(3).Info("Refreshed plan catalog", "plans", len(plans), "operatingSystems", len(operatingSystems))
+}
+
+// listCatalog lists the slugs of the plans and operating systems known to the Equinix Metal API
+func (p *Provider) listCatalog(ctx context.Context, secret *corev1.Secret) (plans, operatingSystems []string, err error) {
+	svc, err := p.createSVC(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	planList, _, err := svc.FindPlans(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing plans failed: %w", err)
+	}
+	osList, _, err := svc.FindOperatingSystems(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing operating systems failed: %w", err)
+	}
+
+	for _, plan := range planList.Plans {
+		plans = append(plans, plan.GetSlug())
+	}
+	for _, os := range osList.OperatingSystems {
+		operatingSystems = append(operatingSystems, os.GetSlug())
+	}
+	return plans, operatingSystems, nil
+}
+
+// validateCatalog validates the plans and the operating system of the provider spec against the catalog, unless the
+// MachineClass allows unknown values with api.AnnotationAllowUnknownCatalogValues
+func (p *Provider) validateCatalog(providerSpec *api.EquinixMetalProviderSpec, machineClass *v1alpha1.MachineClass) error {
+	p.mu.Lock()
+	known := p.catalog
+	p.mu.Unlock()
+	return validateCatalog(providerSpec, machineClass, known)
+}
+
+func validateCatalog(providerSpec *api.EquinixMetalProviderSpec, machineClass *v1alpha1.MachineClass, known *catalog.Catalog) error {
+	if machineClass.Annotations[api.AnnotationAllowUnknownCatalogValues] == "true" {
+		return nil
+	}
+	if errs := validation.ValidateCatalog(providerSpec, known, field.NewPath("providerSpec")); len(errs) > 0 {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("Error while validating ProviderSpec %v", errs.ToAggregate().Error()))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Catalog", func() {
+	providerSecret := newProviderSecret()
+	providerSpec := newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+		spec.MachineType = "x9.large.x86"
+	})
+	createMachine := func(p driver.Driver, i int) error {
+		_, err := p.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+			Machine:      newMachine(i),
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		return err
+	}
+
+	It("should refresh the catalog at most once per interval", func() {
+		plugin := &mock.PluginSPIImpl{Plans: []string{"x9.large.x86"}, OperatingSystems: []string{"alpine_3"}}
+		p := provider.NewProvider(plugin, provider.WithCatalogRefreshInterval(time.Hour))
+
+		Expect(createMachine(p, 1)).To(Succeed())
+		Expect(createMachine(p, 2)).To(Succeed())
+		Expect(plugin.CatalogLookups).To(Equal(1))
+	})
+
+	It("should back off after a failed refresh and reject values unknown to the embedded catalog", func() {
+		plugin := &mock.PluginSPIImpl{FailCatalog: true}
+		p := provider.NewProvider(plugin, provider.WithCatalogRefreshInterval(time.Hour))
+
+		Expect(createMachine(p, 1)).To(MatchError(ContainSubstring(`providerSpec.machineType: Invalid value: "x9.large.x86": unknown machine type`)))
+		Expect(createMachine(p, 2)).To(HaveOccurred())
+		Expect(plugin.CatalogLookups).To(Equal(1))
+		Expect(plugin.Devices).To(BeEmpty())
+	})
+
+	It("should accept unknown values for MachineClasses allowing them", func() {
+		plugin := &mock.PluginSPIImpl{}
+		_, err := provider.NewProvider(plugin).CreateMachine(context.Background(), &driver.CreateMachineRequest{
+			Machine:      newMachine(1),
+			MachineClass: setAnnotation(newMachineClass(providerSpec), api.AnnotationAllowUnknownCatalogValues, "true"),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plugin.Devices).To(HaveLen(1))
+	})
+
+	It("should reject values unknown to the refreshed catalog on creation only", func() {
+		plugin := &mock.PluginSPIImpl{Plans: []string{"c3.small.x86"}, OperatingSystems: []string{"alpine_3"}}
+		p := provider.NewProvider(plugin, provider.WithCatalogRefreshInterval(time.Hour))
+
+		Expect(createMachine(p, 1)).To(MatchError(ContainSubstring(`providerSpec.machineType: Invalid value: "x9.large.x86": unknown machine type`)))
+		_, err := p.ListMachines(context.Background(), &driver.ListMachinesRequest{
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
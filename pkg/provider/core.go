@@ -7,19 +7,23 @@
 package provider
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 
 	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
 	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/catalog"
+	apiv1alpha1 "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/v1alpha1"
+	apiv1alpha2 "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/v1alpha2"
 	validation "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/validation"
 	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/tracing"
 	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
@@ -27,6 +31,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/klog/v2"
+	kjson "sigs.k8s.io/json"
 )
 
 const (
@@ -71,12 +76,18 @@ const (
 // It is optionally expected by the safety controller to use an identification mechanisms to map the VM Created by a providerSpec.
 // These could be done using tag(s)/resource-groups etc.
 // This logic is used by safety controller to delete orphan VMs which are not backed by any machine CRD
-func (p *Provider) CreateMachine(ctx context.Context, req *driver.CreateMachineRequest) (*driver.CreateMachineResponse, error) {
+func (p *Provider) CreateMachine(ctx context.Context, req *driver.CreateMachineRequest) (_ *driver.CreateMachineResponse, err error) {
+	ctx, span := tracing.Start(ctx, "CreateMachine",
+		tracing.AttributeMachine.String(req.Machine.Name),
+		tracing.AttributeMachineClass.String(req.MachineClass.Name))
+	defer func() { tracing.End(span, err) }()
 	// Log messages to track request
-	klog.V(2).Infof("Machine creation request has been received for %q", req.Machine.Name)
+	ctx, logger := logging.WithValues(ctx, logging.KeyMachine, req.Machine.Name, logging.KeyMachineClass, req.MachineClass.Name)
+	logger.V(2).Info("Machine creation request has been received")
 
 	var (
 		userData     string
+		lastErr      error
 		machine      = req.Machine
 		secret       = req.Secret
 		machineClass = req.MachineClass
@@ -92,10 +103,19 @@ func (p *Provider) CreateMachine(ctx context.Context, req *driver.CreateMachineR
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
+	p.refreshCatalog(ctx, secret)
+	if err := p.validateCatalog(providerSpec, machineClass); err != nil {
+		return nil, err
+	}
 	if err := validateSecret(secret); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 
 	}
+	if providerSpec.IPXEScriptSecretKey != "" {
+		if errs := validation.ValidateIPXEScript(secret, providerSpec.IPXEScriptSecretKey); len(errs) > 0 {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid inline iPXE script: %v", errs.ToAggregate().Error()))
+		}
+	}
 	// check that the name was valid
 	if err := validation.ValidateName(machine.Name); len(err) > 0 {
 		var msgs []string
@@ -106,9 +126,22 @@ func (p *Provider) CreateMachine(ctx context.Context, req *driver.CreateMachineR
 		return nil, status.Error(codes.InvalidArgument, strings.Join(msgs, "; "))
 	}
 
+	// tags derived from the machine metadata are only known now, the static ones were validated with the spec
+	derived := derivedTags(providerSpec, machine, machineClass)
+	tags := withProviderIDFormatTag(deviceTags(providerSpec, derived), providerSpec.ProviderIDFormat)
+	errs := validation.ValidateDeviceTags(derived, field.NewPath("derivedTags"))
+	errs = append(errs, validation.ValidateDeviceTagsLength(tags, field.NewPath("tags"))...)
+	if len(errs) > 0 {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid device tags: %v", errs.ToAggregate().Error()))
+	}
+	description := deviceDescription(machine, machineClass)
+	customData, err := deviceCustomData(providerSpec, secret)
+	if err != nil {
+		return nil, err
+	}
 	billingCycle, err := metalv1.NewDeviceCreateInputBillingCycleFromValue(providerSpec.BillingCycle)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
 	svc, err := p.createSVC(req.Secret)
@@ -117,41 +150,130 @@ func (p *Provider) CreateMachine(ctx context.Context, req *driver.CreateMachineR
 	}
 	// we already validated the existence and non-nil-ness of userData in the validation
 	userData = string(secret.Data["userData"])
+	if providerSpec.IPXEScriptSecretKey != "" {
+		// Equinix Metal runs userdata starting with #!ipxe as the iPXE script if there is no script URL
+		userData = string(secret.Data[providerSpec.IPXEScriptSecretKey])
+	}
+	projectIDs := placeProjects(providerSpec)
+
+	if providerSpec.ReplacementStrategy == api.ReplacementStrategyReinstall {
+		update := metalv1.DeviceUpdateInput{
+			Hostname:    &machine.Name,
+			Description: &description,
+			Userdata:    &userData,
+			Tags:        tags,
+			Locked:      metalv1.PtrBool(providerSpec.Locked),
+			AlwaysPxe:   metalv1.PtrBool(providerSpec.AlwaysPXE),
+		}
+		for _, projectID := range projectIDs {
+			ctx, logger := logging.WithValues(ctx, logging.KeyProjectID, projectID)
+			sshKeys, err := projectSSHKeys(ctx, svc, providerSpec, projectID)
+			if err != nil {
+				return nil, err
+			}
+			fingerprint := sshKeysFingerprint(providerSpec, sshKeys)
+			update.Tags = withSSHKeysTag(tags, fingerprint)
+			device, err := p.claimParkedDevice(ctx, svc, providerSpec, projectID, fingerprint, update, customData)
+			if err != nil {
+				logger.Error(err, "Could not reuse parked device")
+				return nil, status.Error(codes.Unavailable, fmt.Sprintf("Could not reuse parked device: %v", err))
+			}
+			if device != nil {
+				key := newDeviceCacheKey(secret, projectID)
+				p.devices.invalidate(key)
+				p.index.watch(key, secret)
+				logger.V(2).Info("Machine creation request has been processed by reinstalling a parked device", logging.KeyDeviceID, device.GetId())
+				return &driver.CreateMachineResponse{
+					ProviderID: encodeMachineID(device, providerSpec.ProviderIDFormat, projectID),
+					NodeName:   machine.Name,
+				}, nil
+			}
+		}
+	}
+
+	// hardware reservations are bound to a metro and plan, so only on-demand devices can fall back
+	metro, plan := providerSpec.Metro, providerSpec.MachineType
+	if len(providerSpec.ReservationIDs) == 0 && !providerSpec.ReservedOnly {
+		metro, plan, err = selectMetroAndPlan(ctx, svc, providerSpec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	customData, err = stampedCustomData(customData, metro, "")
+	if err != nil {
+		return nil, err
+	}
 
-	// packet tags are strings only
+	// packet tags are strings only, the facility is only known once the device is provisioned and stamped by
+	// GetMachineStatus
 	createRequest := metalv1.CreateDeviceRequest{
 		DeviceCreateInMetroInput: &metalv1.DeviceCreateInMetroInput{
-			Metro:           providerSpec.Metro,
+			Metro:           metro,
 			Hostname:        &machine.Name,
+			Description:     &description,
 			Userdata:        &userData,
-			Plan:            providerSpec.MachineType,
+			Plan:            plan,
 			BillingCycle:    billingCycle,
 			OperatingSystem: providerSpec.OS,
 			IpxeScriptUrl:   providerSpec.IPXEScriptURL,
-			ProjectSshKeys:  providerSpec.SSHKeys,
-			Tags:            providerSpec.Tags,
+			UserSshKeys:     providerSpec.UserSSHKeys,
+			Customdata:      customData,
+			Tags:            withTopologyTags(tags, metro, ""),
 		},
 	}
-	klog.V(3).Infof("will create machine with request %#v, reservation IDs %v, reservedOnly %v", createRequest, providerSpec.ReservationIDs, providerSpec.ReservedOnly)
-	device, err := createDeviceWithReservations(
-		ctx,
-		svc,
-		providerSpec.ProjectID,
-		createRequest,
-		providerSpec.ReservationIDs,
-		providerSpec.ReservedOnly)
-	if err != nil {
-		klog.Errorf("Could not create machine: %v", err)
-		return nil, status.Error(codes.Unavailable, fmt.Sprintf("Could not create machine: %v", err))
+	if providerSpec.Locked {
+		createRequest.DeviceCreateInMetroInput.Locked = metalv1.PtrBool(true)
 	}
-
-	response := &driver.CreateMachineResponse{
-		ProviderID: encodeMachineID(device),
-		NodeName:   machine.Name,
+	if providerSpec.AlwaysPXE {
+		createRequest.DeviceCreateInMetroInput.AlwaysPxe = metalv1.PtrBool(true)
+	}
+	if providerSpec.NoSSHKeys {
+		createRequest.DeviceCreateInMetroInput.NoSshKeys = metalv1.PtrBool(true)
 	}
-	klog.V(2).Infof("Machine creation request has been processed for %q", machine.Name)
+	ctx, logger = logging.WithValues(ctx, logging.KeyMetro, metro, logging.KeyPlan, plan)
+	// the other projects are tried in order if the creation in a project fails, e.g. because of its quota
+	for _, projectID := range projectIDs {
+		ctx, logger := logging.WithValues(ctx, logging.KeyProjectID, projectID)
+		sshKeys, err := projectSSHKeys(ctx, svc, providerSpec, projectID)
+		if err != nil {
+			return nil, err
+		}
+		createRequest.DeviceCreateInMetroInput.ProjectSshKeys = sshKeys
+		if providerSpec.ReplacementStrategy == api.ReplacementStrategyReinstall {
+			// parked devices are only reused for the same SSH keys
+			createRequest.DeviceCreateInMetroInput.Tags = withSSHKeysTag(withTopologyTags(tags, metro, ""), sshKeysFingerprint(providerSpec, sshKeys))
+		}
+		createRequest.DeviceCreateInMetroInput.HardwareReservationId = nil
+
+		logger.V(3).Info("Creating device", "reservationIDs", providerSpec.ReservationIDs, "reservedOnly", providerSpec.ReservedOnly)
+		var device *metalv1.Device
+		device, err = createDeviceWithReservations(
+			ctx,
+			svc,
+			projectID,
+			createRequest,
+			providerSpec.ReservationIDs,
+			providerSpec.ReservedOnly,
+			machineClass.Name)
+		if err != nil {
+			logger.Error(err, "Could not create machine")
+			lastErr = err
+			continue
+		}
+		key := newDeviceCacheKey(secret, projectID)
+		p.devices.invalidate(key)
+		p.index.watch(key, secret)
+
+		response := &driver.CreateMachineResponse{
+			ProviderID: encodeMachineID(device, providerSpec.ProviderIDFormat, projectID),
+			NodeName:   machine.Name,
+		}
+		logger.V(2).Info("Machine creation request has been processed", logging.KeyDeviceID, device.GetId())
 
-	return response, nil
+		return response, nil
+	}
+	return nil, status.Error(codes.Unavailable, fmt.Sprintf("Could not create machine: %v", lastErr))
 }
 
 // DeleteMachine handles a machine deletion request
@@ -165,9 +287,14 @@ func (p *Provider) CreateMachine(ctx context.Context, req *driver.CreateMachineR
 // LastKnownState        bytes(blob)              (Optional) Last known state of VM during the current operation.
 //
 //	Could be helpful to continue operations in future requests.
-func (p *Provider) DeleteMachine(ctx context.Context, req *driver.DeleteMachineRequest) (*driver.DeleteMachineResponse, error) {
+func (p *Provider) DeleteMachine(ctx context.Context, req *driver.DeleteMachineRequest) (_ *driver.DeleteMachineResponse, err error) {
+	ctx, span := tracing.Start(ctx, "DeleteMachine",
+		tracing.AttributeMachine.String(req.Machine.Name),
+		tracing.AttributeProviderID.String(req.Machine.Spec.ProviderID))
+	defer func() { tracing.End(span, err) }()
 	// Log messages to track delete request
-	klog.V(2).Infof("Machine deletion request has been received for %q", req.Machine.Name)
+	ctx, logger := logging.WithValues(ctx, logging.KeyMachine, req.Machine.Name, logging.KeyProviderID, req.Machine.Spec.ProviderID)
+	logger.V(2).Info("Machine deletion request has been received")
 
 	// Check if incoming CR is a CR we support
 	if req.MachineClass.Provider != ProviderEquinixMetal {
@@ -182,21 +309,52 @@ func (p *Provider) DeleteMachine(ctx context.Context, req *driver.DeleteMachineR
 	}
 
 	instanceID := decodeMachineID(req.Machine.Spec.ProviderID)
+	ctx, logger = logging.WithValues(ctx, logging.KeyDeviceID, instanceID)
 	svc, err := p.createSVC(req.Secret)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	// devices of MachineClasses that cannot be decoded anymore are deleted
+	if providerSpec, err := decodeProviderSpec(req.MachineClass); err == nil && providerSpec.ReplacementStrategy == api.ReplacementStrategyReinstall {
+		parked, err := p.parkDevice(ctx, svc, req.Machine, instanceID)
+		if err != nil {
+			// locked devices without deletion approval are reported as is
+			var s *status.Status
+			if errors.As(err, &s) {
+				return nil, err
+			}
+			logger.Error(err, "Could not park machine")
+			return nil, status.Error(codes.Unknown, fmt.Sprintf("Could not park machine %s: %v", instanceID, err))
+		}
+		if parked {
+			p.forgetDevice(instanceID)
+			logger.V(2).Info("Machine deletion request has been processed by parking the device")
+			return &driver.DeleteMachineResponse{}, nil
+		}
+	}
 	resp, err := svc.DeleteDevice(ctx, instanceID)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		// locked devices are only unlocked with approval, the deletion fails for other reasons otherwise
+		if device, _, findErr := svc.FindDeviceByID(ctx, instanceID); findErr == nil && device.GetLocked() {
+			if err := unlockForDeletion(ctx, svc, req.Machine, instanceID); err != nil {
+				return nil, err
+			}
+			resp, err = svc.DeleteDevice(ctx, instanceID)
+		}
+	}
+	if err == nil || (resp != nil && resp.StatusCode == http.StatusNotFound) {
+		p.forgetDevice(instanceID)
+	}
 	if err != nil {
-		if resp.StatusCode == 404 {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
 			// if it is not found, do not error, just return
-			klog.V(2).Infof("No machine matching the machine-ID found on the provider %q", instanceID)
+			logger.V(2).Info("No device matching the machine-ID found on the provider")
 			return &driver.DeleteMachineResponse{}, nil
 		}
-		klog.Errorf("Could not terminate machine %s: %v", instanceID, err)
+		logger.Error(err, "Could not terminate machine")
 		return nil, status.Error(codes.Unknown, fmt.Sprintf("Could not terminate machine %s: %v", instanceID, err))
 	}
-	klog.V(2).Infof("Machine deletion request has been processed for %q", req.Machine.Name)
+	logger.V(2).Info("Machine deletion request has been processed")
 	return &driver.DeleteMachineResponse{}, nil
 }
 
@@ -219,14 +377,19 @@ func (p *Provider) DeleteMachine(ctx context.Context, req *driver.DeleteMachineR
 //	This could be different from req.MachineName as well
 //
 // The request should return a NOT_FOUND (5) status error code if the machine is not existing
-func (p *Provider) GetMachineStatus(ctx context.Context, req *driver.GetMachineStatusRequest) (*driver.GetMachineStatusResponse, error) {
-	// Log messages to track start and end of request
-	klog.V(2).Infof("Get request has been received for %q", req.Machine.Name)
+func (p *Provider) GetMachineStatus(ctx context.Context, req *driver.GetMachineStatusRequest) (_ *driver.GetMachineStatusResponse, err error) {
+	ctx, span := tracing.Start(ctx, "GetMachineStatus",
+		tracing.AttributeMachine.String(req.Machine.Name),
+		tracing.AttributeProviderID.String(req.Machine.Spec.ProviderID))
+	defer func() { tracing.End(span, err) }()
 
 	var (
 		id   = decodeMachineID(req.Machine.Spec.ProviderID)
 		name = req.Machine.Name
 	)
+	// Log messages to track start and end of request
+	ctx, logger := logging.WithValues(ctx, logging.KeyMachine, name, logging.KeyDeviceID, id)
+	logger.V(2).Info("Get request has been received")
 
 	// Check if incoming CR is a CR we support
 	if req.MachineClass.Provider != ProviderEquinixMetal {
@@ -243,15 +406,25 @@ func (p *Provider) GetMachineStatus(ctx context.Context, req *driver.GetMachineS
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	device, _, err := svc.FindDeviceByID(ctx, id)
-	if err != nil {
-		return nil, status.Error(codes.NotFound, fmt.Sprintf("Could not get device %s: %v", id, err))
+	device, ok := p.knownDevice(req.MachineClass, req.Secret, id)
+	if !ok {
+		device, _, err = svc.FindDeviceByID(ctx, id)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, fmt.Sprintf("Could not get device %s: %v", id, err))
+		}
 	}
+	// parked devices are no longer machines, although they still exist
+	if isParked(device) {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("Device %s has been parked for reuse", id))
+	}
+	device = p.stampProviderIDFormat(ctx, svc, req.Machine.Spec.ProviderID, device)
+	device = p.stampTopology(ctx, svc, device)
+	p.performRequestedAction(ctx, svc, req.Machine, device)
 
-	klog.V(2).Infof("Machine get request has been processed successfully for %q", name)
+	logger.V(2).Info("Machine get request has been processed successfully")
 	return &driver.GetMachineStatusResponse{
 		NodeName:   name,
-		ProviderID: encodeMachineID(device),
+		ProviderID: reencodeMachineID(device, req.Machine.Spec.ProviderID),
 	}, nil
 }
 
@@ -268,13 +441,15 @@ func (p *Provider) GetMachineStatus(ctx context.Context, req *driver.GetMachineS
 // MachineList           map<string,string>  A map containing the keys as the MachineID and value as the MachineName
 //
 //	for all machine's who where possibilly created by this ProviderSpec
-func (p *Provider) ListMachines(ctx context.Context, req *driver.ListMachinesRequest) (*driver.ListMachinesResponse, error) {
+func (p *Provider) ListMachines(ctx context.Context, req *driver.ListMachinesRequest) (_ *driver.ListMachinesResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ListMachines", tracing.AttributeMachineClass.String(req.MachineClass.Name))
+	defer func() { tracing.End(span, err) }()
 	// Log messages to track start and end of request
-	klog.V(2).Infof("List machines request has been received for %q", req.MachineClass.Name)
+	ctx, logger := logging.WithValues(ctx, logging.KeyMachineClass, req.MachineClass.Name)
+	logger.V(2).Info("List machines request has been received")
 
 	var (
-		clusterName, nodeRole string
-		resp                  = &driver.ListMachinesResponse{
+		resp = &driver.ListMachinesResponse{
 			MachineList: make(map[string]string),
 		}
 	)
@@ -292,15 +467,8 @@ func (p *Provider) ListMachines(ctx context.Context, req *driver.ListMachinesReq
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	for _, key := range providerSpec.Tags {
-		if strings.Contains(key, "kubernetes.io/cluster/") {
-			clusterName = key
-		} else if strings.Contains(key, "kubernetes.io/role/") {
-			nodeRole = key
-		}
-	}
-
-	if clusterName == "" || nodeRole == "" {
+	clusterTag, roleTag := api.ClusterAndRoleTags(providerSpec.AllTags())
+	if clusterTag == nil || roleTag == nil {
 		return resp, nil
 	}
 
@@ -308,25 +476,37 @@ func (p *Provider) ListMachines(ctx context.Context, req *driver.ListMachinesReq
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	deviceList, _, err := svc.FindProjectDevices(ctx, providerSpec.ProjectID)
-	if err != nil {
-		msg := fmt.Sprintf("Could not list devices for project %s: %v", providerSpec.ProjectID, err)
-		klog.Error(msg)
-		return nil, status.Error(codes.Unknown, msg)
-	}
-	for _, d := range deviceList.Devices {
-		matchedCluster := false
-		matchedRole := false
-		for _, tag := range d.Tags {
-			switch tag {
-			case clusterName:
-				matchedCluster = true
-			case nodeRole:
-				matchedRole = true
-			}
+	for _, projectID := range providerSpec.ProjectIDs() {
+		ctx, logger := logging.WithValues(ctx, logging.KeyProjectID, projectID)
+		key := newDeviceCacheKey(req.Secret, projectID)
+		p.index.watch(key, req.Secret)
+		p.reservations.watch(key, req.Secret, req.MachineClass.Name, providerSpec.ReservationIDs)
+		devices, err := p.devices.list(ctx, svc, key)
+		if err != nil {
+			logger.Error(err, "Could not list devices")
+			return nil, status.Error(codes.Unknown, fmt.Sprintf("Could not list devices for project %s: %v", projectID, err))
 		}
-		if matchedCluster && matchedRole {
-			resp.MachineList[encodeMachineID(&d)] = *d.Hostname
+		for _, d := range devices {
+			matchedCluster := false
+			matchedRole := false
+			for _, tag := range api.ParseTags(d.Tags) {
+				switch tag {
+				case *clusterTag:
+					matchedCluster = true
+				case *roleTag:
+					matchedRole = true
+				}
+			}
+			if !matchedCluster || !matchedRole {
+				continue
+			}
+			// devices keep the ProviderID of their machines when the format of the MachineClass changes, otherwise
+			// the safety controller would delete them as orphans
+			format := providerIDFormatTag(d.Tags)
+			if format == "" {
+				format = providerSpec.ProviderIDFormat
+			}
+			resp.MachineList[encodeMachineID(&d, format, projectID)] = *d.Hostname
 		}
 	}
 	return resp, nil
@@ -339,14 +519,30 @@ func (p *Provider) ListMachines(ctx context.Context, req *driver.ListMachinesReq
 //
 // RESPONSE PARAMETERS (driver.GetVolumeIDsResponse)
 // VolumeIDs             []string                             VolumeIDs is a repeated list of VolumeIDs.
-func (p *Provider) GetVolumeIDs(ctx context.Context, req *driver.GetVolumeIDsRequest) (*driver.GetVolumeIDsResponse, error) {
+func (p *Provider) GetVolumeIDs(ctx context.Context, req *driver.GetVolumeIDsRequest) (_ *driver.GetVolumeIDsResponse, err error) {
 	// Log messages to track start and end of request
-	klog.V(2).Infof("GetVolumeIDs request has been received for %q", req.PVSpecs)
-	defer klog.V(2).Infof("GetVolumeIDs request has been processed successfully for %q", req.PVSpecs)
+	logger := klog.FromContext(ctx)
+	logger.V(2).Info("GetVolumeIDs request has been received", "pvSpecs", len(req.PVSpecs))
+	defer logger.V(2).Info("GetVolumeIDs request has been processed successfully")
+	_, span := tracing.Start(ctx, "GetVolumeIDs")
+	defer func() { tracing.End(span, err) }()
 
 	return &driver.GetVolumeIDsResponse{}, status.Error(codes.Unimplemented, "Equinix Metal does not have storage")
 }
 
+// ValidateMachineClass validates that the MachineClass is an EquinixMetal MachineClass with a valid provider spec,
+// whose plans and operating system are known to the embedded catalog unless the MachineClass allows unknown values
+func ValidateMachineClass(machineClass *v1alpha1.MachineClass) error {
+	if machineClass.Provider != ProviderEquinixMetal {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("Requested for Provider '%s', we only support '%s'", machineClass.Provider, ProviderEquinixMetal))
+	}
+	providerSpec, err := decodeProviderSpec(machineClass)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return validateCatalog(providerSpec, machineClass, catalog.Embedded())
+}
+
 // create a session
 func (p *Provider) createSVC(secret *corev1.Secret) (spi.MetalDeviceService, error) {
 	return p.SPI.NewSession(secret)
@@ -354,35 +550,125 @@ func (p *Provider) createSVC(secret *corev1.Secret) (spi.MetalDeviceService, err
 
 // decodeProviderSpec converts request parameters to api.ProviderSpec & api.Secrets
 func decodeProviderSpec(machineClass *v1alpha1.MachineClass) (*api.EquinixMetalProviderSpec, error) {
-	var (
-		providerSpec *api.EquinixMetalProviderSpec
-	)
-
 	// Extract providerSpec
 	if machineClass == nil {
 		return nil, status.Error(codes.Internal, "MachineClass ProviderSpec is nil")
 	}
 
-	err := json.Unmarshal(machineClass.ProviderSpec.Raw, &providerSpec)
+	strict := machineClass.Annotations[api.AnnotationAllowUnknownFields] != "true"
+	providerSpec, fieldErrs, err := decodeVersionedProviderSpec(machineClass.ProviderSpec.Raw, strict, field.NewPath("providerSpec"))
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	if len(fieldErrs) > 0 {
+		klog.V(2).InfoS("Decoding of EquinixMetalMachineClass failed", logging.KeyMachineClass, machineClass.Name, "err", fieldErrs.ToAggregate())
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Error while decoding ProviderSpec %v", fieldErrs.ToAggregate().Error()))
+	}
 
 	// Validate the Spec and Secrets
 	validationErr := validation.ValidateProviderSpec(providerSpec, field.NewPath("providerSpec"))
 	if validationErr.ToAggregate() != nil && len(validationErr.ToAggregate().Errors()) > 0 {
 		err = fmt.Errorf("Error while validating ProviderSpec %v", validationErr.ToAggregate().Error())
-		klog.V(2).Infof("Validation of EquinixMetalMachineClass failed %s", err)
+		klog.V(2).InfoS("Validation of EquinixMetalMachineClass failed", logging.KeyMachineClass, machineClass.Name, "err", validationErr.ToAggregate())
 
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	if providerSpec.IPXEScriptURL != nil {
-		providerSpec.OS = "custom_ipxe"
+	if providerSpec.IPXEScriptURL != nil || providerSpec.IPXEScriptSecretKey != "" {
+		providerSpec.OS = api.OSCustomIPXE
 	}
 
 	return providerSpec, nil
 }
 
+// decodeVersionedProviderSpec decodes the raw provider spec in the version given by its apiVersion and converts it
+// to the internal provider spec. Specs without apiVersion are decoded as v1alpha1 for compatibility.
+// If strict is set, keys are matched case-sensitively and unknown or duplicate fields are returned as field errors,
+// otherwise they are ignored.
+func decodeVersionedProviderSpec(raw []byte, strict bool, fldPath *field.Path) (*api.EquinixMetalProviderSpec, field.ErrorList, error) {
+	var (
+		typeMeta struct {
+			APIVersion string `json:"apiVersion"`
+		}
+		providerSpec = &api.EquinixMetalProviderSpec{}
+		fieldErrs    field.ErrorList
+	)
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, nil, err
+	}
+
+	decode := func(into interface{}) error {
+		if !strict {
+			return json.Unmarshal(raw, into)
+		}
+		strictErrs, err := kjson.UnmarshalStrict(raw, into)
+		if err != nil {
+			return err
+		}
+		fieldErrs = strictFieldErrors(strictErrs, fldPath)
+		return nil
+	}
+
+	switch typeMeta.APIVersion {
+	case "", api.V1alpha1:
+		spec := &apiv1alpha1.EquinixMetalProviderSpec{}
+		if err := decode(spec); err != nil {
+			return nil, nil, err
+		}
+		apiv1alpha1.SetDefaults(spec)
+		spec.ConvertTo(providerSpec)
+	case api.V1alpha2:
+		spec := &apiv1alpha2.EquinixMetalProviderSpec{}
+		if err := decode(spec); err != nil {
+			return nil, nil, err
+		}
+		apiv1alpha2.SetDefaults(spec)
+		spec.ConvertTo(providerSpec)
+	default:
+		return nil, nil, fmt.Errorf("unsupported apiVersion %q, supported are %q and %q", typeMeta.APIVersion, api.V1alpha1, api.V1alpha2)
+	}
+
+	return providerSpec, fieldErrs, nil
+}
+
+// strictFieldErrors converts the errors of a strict decoding to field errors below fldPath
+func strictFieldErrors(strictErrs []error, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, err := range strictErrs {
+		fieldErr, ok := err.(kjson.FieldError)
+		if !ok {
+			allErrs = append(allErrs, field.InternalError(fldPath, err))
+			continue
+		}
+		// the message is either `unknown field "<path>"` or `duplicate field "<path>"`
+		detail := strings.SplitN(err.Error(), " \"", 2)[0]
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child(fieldErr.FieldPath()), detail))
+	}
+	return allErrs
+}
+
+// knownDevice returns the device from the device index or the cached device listing of the projects of the
+// MachineClass, if any
+func (p *Provider) knownDevice(machineClass *v1alpha1.MachineClass, secret *corev1.Secret, deviceID string) (*metalv1.Device, bool) {
+	if p.devices.ttl == 0 && p.index.interval == 0 {
+		return nil, false
+	}
+	providerSpec, err := decodeProviderSpec(machineClass)
+	if err != nil {
+		return nil, false
+	}
+	for _, projectID := range providerSpec.ProjectIDs() {
+		key := newDeviceCacheKey(secret, projectID)
+		p.index.watch(key, secret)
+		if device, ok := p.index.device(key, deviceID); ok {
+			return device, true
+		}
+		if device, ok := p.devices.device(key, deviceID); ok {
+			return device, true
+		}
+	}
+	return nil, false
+}
+
 func createDeviceWithReservations(
 	ctx context.Context,
 	svc spi.MetalDeviceService,
@@ -390,10 +676,12 @@ func createDeviceWithReservations(
 	createRequest metalv1.CreateDeviceRequest,
 	reservationIDs []string,
 	reservedOnly bool,
+	machineClassName string,
 ) (device *metalv1.Device, err error) {
+	logger := klog.FromContext(ctx)
 	// if there were no reservation IDs and I didn't ask for reservedOnly, then just create one on-demand and return
 	if len(reservationIDs) == 0 && !reservedOnly {
-		klog.V(2).Info("No reservation ids provided, creating a on demand instance")
+		logger.V(2).Info("No reservation ids provided, creating an on-demand device")
 		device, _, err = svc.CreateDevice(ctx, projectID, createRequest)
 		return device, err
 	}
@@ -402,23 +690,21 @@ func createDeviceWithReservations(
 	// In both cases, we try reservations first.
 	for _, resID := range reservationIDs {
 		createRequest.DeviceCreateInMetroInput.HardwareReservationId = &resID
-		var res *http.Response
-		device, res, err = svc.CreateDevice(ctx, projectID, createRequest)
+		device, _, err = svc.CreateDevice(ctx, projectID, createRequest)
 		// if no error, we got the device, return it
 		if err == nil {
 			return device, err
 		}
-		body := bytes.NewBuffer([]byte{})
-		if _, err := io.ReadAll(res.Request.Body); err != nil {
-			klog.Error(err)
-		}
-		klog.Errorf("Error while creating machine with reservation id %s: Request: %s Error: %v", resID, body.String(), err)
+		logger.Error(err, "Could not create device with hardware reservation", logging.KeyReservationID, resID)
 	}
 	// if we got here, we failed to get a device with the given hardware reservation
 	if reservedOnly {
 		return nil, errors.New("could not get a device with the provided reservation IDs, and reservedOnly is true")
 	}
 	// now just create a device on demand
+	logger.V(2).Info("No hardware reservation available, creating an on-demand device")
+	metrics.ReservationOnDemandFallbacks.WithLabelValues(machineClassName).Inc()
+	createRequest.DeviceCreateInMetroInput.HardwareReservationId = nil
 	device, _, err = svc.CreateDevice(ctx, projectID, createRequest)
 	return device, err
 }
@@ -430,18 +716,9 @@ func validateSecret(secret *corev1.Secret, fields ...string) error {
 	validationErr := validation.ValidateSecret(secret, fields...)
 	if validationErr.ToAggregate() != nil && len(validationErr.ToAggregate().Errors()) > 0 {
 		err := fmt.Errorf("Error while validating Secret %v", validationErr.ToAggregate().Error())
-		klog.V(2).Infof("Validation of Secret failed %s", err)
+		klog.V(2).InfoS("Validation of Secret failed", "err", validationErr.ToAggregate())
 
 		return status.Error(codes.Internal, err.Error())
 	}
 	return nil
 }
-
-func encodeMachineID(device *metalv1.Device) string {
-	return fmt.Sprintf("equinixmetal://%s/%s", *device.Metro.Code, *device.Id)
-}
-
-func decodeMachineID(id string) string {
-	splitProviderID := strings.Split(id, "/")
-	return splitProviderID[len(splitProviderID)-1]
-}
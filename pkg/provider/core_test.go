@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
 	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
@@ -29,7 +31,7 @@ var _ = Describe("MachineServer", func() {
 		Metro:        "ny",
 		MachineType:  "c3.small.x86",
 		BillingCycle: "hourly",
-		OS:           "alpine_3.13",
+		OS:           "alpine_3",
 		ProjectID:    "abcdefg",
 		Tags: []string{
 			"kubernetes.io/cluster/shoot-test: 1",
@@ -37,6 +39,78 @@ var _ = Describe("MachineServer", func() {
 		},
 	}
 	providerSpec, _ := json.Marshal(providerSpecStruct)
+	derivedTagsSpecStruct := providerSpecStruct
+	derivedTagsSpecStruct.DerivedTags = &api.DerivedTags{
+		MachineName:   true,
+		Namespace:     true,
+		MachineClass:  true,
+		MachineLabels: []string{"node", "missing"},
+	}
+	derivedTagsSpec, _ := json.Marshal(derivedTagsSpecStruct)
+	invalidTagSpecStruct := derivedTagsSpecStruct
+	invalidTagSpecStruct.DerivedTags = &api.DerivedTags{
+		MachineLabels: []string{"invalid"},
+	}
+	invalidTagSpec, _ := json.Marshal(invalidTagSpecStruct)
+	longTagsSpecStruct := derivedTagsSpecStruct
+	longTagsSpecStruct.DerivedTags = &api.DerivedTags{}
+	longTagsMachine := newMachine(0)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("label-%d", i)
+		longTagsSpecStruct.DerivedTags.MachineLabels = append(longTagsSpecStruct.DerivedTags.MachineLabels, key)
+		setLabel(longTagsMachine, key, strings.Repeat("x", 240))
+	}
+	longTagsSpec, _ := json.Marshal(longTagsSpecStruct)
+	labelsSpecStruct := providerSpecStruct
+	labelsSpecStruct.Tags = []string{"kubernetes.io/cluster/shoot-test=1"}
+	labelsSpecStruct.Labels = map[string]string{
+		"kubernetes.io/role/test": "1",
+	}
+	labelsSpec, _ := json.Marshal(labelsSpecStruct)
+	fallbackSpecStruct := providerSpecStruct
+	fallbackSpecStruct.FallbackMetros = []string{"da"}
+	fallbackSpecStruct.FallbackMachineTypes = []string{"m3.small.x86"}
+	fallbackSpec, _ := json.Marshal(fallbackSpecStruct)
+	typoSpecStruct := providerSpecStruct
+	typoSpecStruct.MachineType = "c3.smal.x86"
+	typoSpec, _ := json.Marshal(typoSpecStruct)
+	newPlanSpecStruct := providerSpecStruct
+	newPlanSpecStruct.MachineType = "x9.large.x86"
+	newPlanSpec, _ := json.Marshal(newPlanSpecStruct)
+	v1alpha2Spec := []byte(`{
+		"apiVersion": "mcm.gardener.cloud/v1alpha2",
+		"projectID": "abcdefg",
+		"placement": {"metros": ["da", "ny"]},
+		"machine": {"types": ["m3.small.x86"]},
+		"operatingSystem": {"slug": "alpine_3"},
+		"tags": ["kubernetes.io/cluster/shoot-test: 1", "kubernetes.io/role/test: 1"]
+	}`)
+	v1alpha2UnknownFieldSpec := []byte(`{
+		"apiVersion": "mcm.gardener.cloud/v1alpha2",
+		"projectID": "abcdefg",
+		"metro": "ny"
+	}`)
+	v1alpha2FieldInV1alpha1Spec := []byte(`{
+		"apiVersion": "mcm.gardener.cloud/v1alpha1",
+		"metro": "ny",
+		"machineType": "c3.small.x86",
+		"billingCycle": "hourly",
+		"OS": "alpine_3",
+		"projectID": "abcdefg",
+		"tags": ["kubernetes.io/cluster/shoot-test: 1", "kubernetes.io/role/test: 1"],
+		"locked": true
+	}`)
+	unknownFieldsSpec := []byte(`{
+		"metro": "ny",
+		"facilities": ["ny5"],
+		"machineType": "c3.small.x86",
+		"billingCycle": "hourly",
+		"os": "alpine_3",
+		"projectID": "abcdefg",
+		"tags": ["kubernetes.io/cluster/shoot-test: 1", "kubernetes.io/role/test: 1"],
+		"reservedOnly": true
+	}`)
+	unsupportedVersionSpec := []byte(`{"apiVersion": "mcm.gardener.cloud/v2", "projectID": "abcdefg"}`)
 	providerSecret := &corev1.Secret{
 		Data: map[string][]byte{
 			"apiToken": []byte("dummy-token"),
@@ -46,12 +120,18 @@ var _ = Describe("MachineServer", func() {
 
 	Describe("#CreateMachine", func() {
 		type setup struct {
+			noCapacity       map[string]bool
+			plans            []string
+			operatingSystems []string
 		}
 		type action struct {
 			machineRequest *driver.CreateMachineRequest
 		}
 		type expect struct {
 			machineResponse   *driver.CreateMachineResponse
+			deviceTags        []string
+			deviceDescription string
+			devicePlan        string
 			errToHaveOccurred bool
 			errMessage        string
 		}
@@ -62,8 +142,16 @@ var _ = Describe("MachineServer", func() {
 		}
 		DescribeTable("##table",
 			func(data *data) {
-				plugin := &mock.PluginSPIImpl{}
-				p := provider.NewProvider(plugin)
+				plugin := &mock.PluginSPIImpl{
+					NoCapacity:       data.setup.noCapacity,
+					Plans:            data.setup.plans,
+					OperatingSystems: data.setup.operatingSystems,
+				}
+				var opts []provider.Option
+				if data.setup.plans != nil {
+					opts = append(opts, provider.WithCatalogRefreshInterval(time.Nanosecond))
+				}
+				p := provider.NewProvider(plugin, opts...)
 				ctx := context.Background()
 				response, err := p.CreateMachine(ctx, data.action.machineRequest)
 				if data.expect.errToHaveOccurred {
@@ -73,6 +161,15 @@ var _ = Describe("MachineServer", func() {
 					Expect(err).ToNot(HaveOccurred())
 					Expect(data.expect.machineResponse.ProviderID).To(Equal(response.ProviderID))
 					Expect(data.expect.machineResponse.NodeName).To(Equal(response.NodeName))
+					if data.expect.deviceTags != nil {
+						Expect(plugin.Devices[0].Tags).To(Equal(data.expect.deviceTags))
+					}
+					if data.expect.deviceDescription != "" {
+						Expect(*plugin.Devices[0].Description).To(Equal(data.expect.deviceDescription))
+					}
+					if data.expect.devicePlan != "" {
+						Expect(*plugin.Devices[0].Plan.Slug).To(Equal(data.expect.devicePlan))
+					}
 				}
 			},
 			Entry("simple", &data{
@@ -91,6 +188,261 @@ var _ = Describe("MachineServer", func() {
 					errToHaveOccurred: false,
 				},
 			}),
+			Entry("derived tags", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(0),
+						MachineClass: setName(newMachineClass(derivedTagsSpec), "eqx-mc"),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					machineResponse: &driver.CreateMachineResponse{
+						ProviderID: "equinixmetal://ny/000001",
+						NodeName:   "machine-0",
+					},
+					deviceTags: []string{
+						"kubernetes.io/cluster/shoot-test: 1",
+						"kubernetes.io/role/test: 1",
+						"mcm.gardener.cloud/machine: machine-0",
+						"mcm.gardener.cloud/namespace: test",
+						"mcm.gardener.cloud/machineclass: eqx-mc",
+						"node: machine-0",
+						"equinixmetal.gardener.cloud/provider-id-format: metro",
+						"equinixmetal.gardener.cloud/metro: ny",
+					},
+					deviceDescription: "Machine test/machine-0 of MachineClass eqx-mc, managed by the Gardener machine-controller-manager",
+				},
+			}),
+			Entry("fallback metro with capacity", &data{
+				setup: setup{
+					noCapacity: map[string]bool{"ny/c3.small.x86": true, "ny/m3.small.x86": true},
+				},
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(fallbackSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					machineResponse: &driver.CreateMachineResponse{
+						ProviderID: "equinixmetal://da/000001",
+						NodeName:   "machine-0",
+					},
+					devicePlan: "c3.small.x86",
+				},
+			}),
+			Entry("fallback plan with capacity", &data{
+				setup: setup{
+					noCapacity: map[string]bool{"ny/c3.small.x86": true},
+				},
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(fallbackSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					machineResponse: &driver.CreateMachineResponse{
+						ProviderID: "equinixmetal://ny/000001",
+						NodeName:   "machine-0",
+					},
+					devicePlan: "m3.small.x86",
+				},
+			}),
+			Entry("no capacity", &data{
+				setup: setup{
+					noCapacity: map[string]bool{"ny/c3.small.x86": true},
+				},
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(providerSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [ResourceExhausted] message = [No capacity available for any of the plans [c3.small.x86] in any of the metros [ny]]",
+				},
+			}),
+			Entry("machine type unknown to the embedded catalog", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(typoSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [InvalidArgument] message = [Error while validating ProviderSpec providerSpec.machineType: Invalid value: \"c3.smal.x86\": unknown machine type, did you mean one of [c3.small.x86 m3.small.x86 t3.small.x86]]",
+				},
+			}),
+			Entry("allowed machine type unknown to the embedded catalog", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: setAnnotation(newMachineClass(typoSpec), api.AnnotationAllowUnknownCatalogValues, "true"),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					machineResponse: &driver.CreateMachineResponse{
+						ProviderID: "equinixmetal://ny/000001",
+						NodeName:   "machine-0",
+					},
+					devicePlan: "c3.smal.x86",
+				},
+			}),
+			Entry("machine type unknown to the refreshed catalog", &data{
+				setup: setup{
+					plans:            []string{"x9.large.x86"},
+					operatingSystems: []string{"alpine_3"},
+				},
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(typoSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [InvalidArgument] message = [Error while validating ProviderSpec providerSpec.machineType: Invalid value: \"c3.smal.x86\": unknown machine type, did you mean one of [c3.small.x86 m3.small.x86 t3.small.x86]]",
+				},
+			}),
+			Entry("machine type from refreshed catalog", &data{
+				setup: setup{
+					plans:            []string{"x9.large.x86"},
+					operatingSystems: []string{"alpine_3"},
+				},
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(newPlanSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					machineResponse: &driver.CreateMachineResponse{
+						ProviderID: "equinixmetal://ny/000001",
+						NodeName:   "machine-0",
+					},
+					devicePlan: "x9.large.x86",
+				},
+			}),
+			Entry("v1alpha2 provider spec", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(v1alpha2Spec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					machineResponse: &driver.CreateMachineResponse{
+						ProviderID: "equinixmetal://da/000001",
+						NodeName:   "machine-0",
+					},
+					devicePlan: "m3.small.x86",
+				},
+			}),
+			Entry("unknown field in versioned provider spec", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(v1alpha2UnknownFieldSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [InvalidArgument] message = [machine codes error: code = [InvalidArgument] message = [Error while decoding ProviderSpec providerSpec.metro: Forbidden: unknown field]]",
+				},
+			}),
+			Entry("option only available in v1alpha2 in v1alpha1 provider spec", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(v1alpha2FieldInV1alpha1Spec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [InvalidArgument] message = [machine codes error: code = [InvalidArgument] message = [Error while decoding ProviderSpec providerSpec.locked: Forbidden: unknown field]]",
+				},
+			}),
+			Entry("unknown and mis-cased fields in provider spec", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(unknownFieldsSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [InvalidArgument] message = [machine codes error: code = [InvalidArgument] message = [Error while decoding ProviderSpec [providerSpec.facilities: Forbidden: unknown field, providerSpec.os: Forbidden: unknown field, providerSpec.reservedOnly: Forbidden: unknown field]]]",
+				},
+			}),
+			Entry("unknown fields allowed by annotation", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: setAnnotation(newMachineClass(unknownFieldsSpec), api.AnnotationAllowUnknownFields, "true"),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					machineResponse: &driver.CreateMachineResponse{
+						ProviderID: "equinixmetal://ny/000001",
+						NodeName:   "machine-0",
+					},
+				},
+			}),
+			Entry("unsupported provider spec version", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      newMachine(-1),
+						MachineClass: newMachineClass(unsupportedVersionSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [InvalidArgument] message = [machine codes error: code = [Internal] message = [unsupported apiVersion \"mcm.gardener.cloud/v2\", supported are \"mcm.gardener.cloud/v1alpha1\" and \"mcm.gardener.cloud/v1alpha2\"]]",
+				},
+			}),
+			Entry("invalid derived tag", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      setLabel(newMachine(0), "invalid", "a,b"),
+						MachineClass: newMachineClass(invalidTagSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [InvalidArgument] message = [Invalid device tags: derivedTags[0]: Invalid value: \"invalid: a,b\": tag did not match allowed regex '^[-a-zA-Z0-9_.:/=@+ ]+$']",
+				},
+			}),
+			Entry("derived tags too long", &data{
+				action: action{
+					machineRequest: &driver.CreateMachineRequest{
+						Machine:      longTagsMachine,
+						MachineClass: newMachineClass(longTagsSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: true,
+					errMessage:        "machine codes error: code = [InvalidArgument] message = [Invalid device tags: tags: Too long: must have at most 4096 bytes]",
+				},
+			}),
 			Entry("wrong provider", &data{
 				action: action{
 					machineRequest: &driver.CreateMachineRequest{
@@ -423,6 +775,37 @@ var _ = Describe("MachineServer", func() {
 					},
 				},
 			}),
+			Entry("labels and key=value tags", &data{
+				setup: setup{
+					createMachineRequest: []*driver.CreateMachineRequest{
+						{
+							Machine:      newMachine(0),
+							MachineClass: newMachineClass(providerSpec),
+							Secret:       providerSecret,
+						},
+						{
+							Machine:      newMachine(1),
+							MachineClass: newMachineClass(labelsSpec),
+							Secret:       providerSecret,
+						},
+					},
+				},
+				action: action{
+					listMachineRequest: &driver.ListMachinesRequest{
+						MachineClass: newMachineClass(labelsSpec),
+						Secret:       providerSecret,
+					},
+				},
+				expect: expect{
+					errToHaveOccurred: false,
+					listMachineResponse: &driver.ListMachinesResponse{
+						MachineList: map[string]string{
+							"equinixmetal:///ewr1/000000": "machine-0",
+							"equinixmetal:///ewr1/000001": "machine-1",
+						},
+					},
+				},
+			}),
 			Entry("wrong provider", &data{
 				action: action{
 					listMachineRequest: &driver.ListMachinesRequest{
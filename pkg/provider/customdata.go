@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/validation"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// deviceCustomData returns the customdata of the provider spec merged with the JSON object in the
+// CustomDataSecretKey of the secret, whose values take precedence. Nested objects are merged recursively.
+func deviceCustomData(providerSpec *api.EquinixMetalProviderSpec, secret *corev1.Secret) (map[string]interface{}, error) {
+	var customData map[string]interface{}
+	if len(providerSpec.CustomData) > 0 {
+		if err := json.Unmarshal(providerSpec.CustomData, &customData); err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid customData: %v", err))
+		}
+	}
+	if providerSpec.CustomDataSecretKey == "" {
+		return customData, nil
+	}
+
+	fldPath := field.NewPath("secretRef").Child(providerSpec.CustomDataSecretKey)
+	raw, ok := secret.Data[providerSpec.CustomDataSecretKey]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, field.Required(fldPath, "Required customData").Error())
+	}
+	if errs := validation.ValidateCustomData(raw, fldPath); len(errs) > 0 {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid customData: %v", errs.ToAggregate().Error()))
+	}
+	var secretData map[string]interface{}
+	if err := json.Unmarshal(raw, &secretData); err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid customData: %v", err))
+	}
+	customData = mergeObjects(customData, secretData)
+
+	merged, err := json.Marshal(customData)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if errs := validation.ValidateCustomData(merged, field.NewPath("customData")); len(errs) > 0 {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid merged customData: %v", errs.ToAggregate().Error()))
+	}
+	return customData, nil
+}
+
+// mergeObjects merges the overlay into the base object, the values of the overlay take precedence unless both values
+// are objects, which are merged recursively
+func mergeObjects(base, overlay map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		base = make(map[string]interface{}, len(overlay))
+	}
+	for key, value := range overlay {
+		baseObject, baseOK := base[key].(map[string]interface{})
+		object, ok := value.(map[string]interface{})
+		if baseOK && ok {
+			base[key] = mergeObjects(baseObject, object)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
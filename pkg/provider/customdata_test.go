@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/validation"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("CustomData", func() {
+	fromSecret := newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.CustomDataSecretKey = "customData" })
+
+	// the metro is stamped on every device
+	table.DescribeTable("should pass the customdata",
+		func(spec []byte, secret *corev1.Secret, expected string) {
+			plugin, err := createMachine(spec, secret)
+			Expect(err).NotTo(HaveOccurred())
+			customData, _ := json.Marshal(plugin.Devices[0].Customdata)
+			Expect(customData).To(MatchJSON(expected))
+		},
+		table.Entry("without customdata", newProviderSpec(nil), newProviderSecret(), `{"topology":{"metro":"ny"}}`),
+		table.Entry("from the provider spec",
+			newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.CustomData = json.RawMessage(`{"role":"worker"}`) }),
+			newProviderSecret(), `{"role":"worker","topology":{"metro":"ny"}}`),
+		table.Entry("from the secret", fromSecret,
+			newProviderSecretWith("customData", `{"token":"secret"}`), `{"token":"secret","topology":{"metro":"ny"}}`),
+		table.Entry("merged with the secret taking precedence",
+			newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+				spec.CustomData = json.RawMessage(`{"role":"worker","agent":{"url":"https://example.com","token":"none"}}`)
+				spec.CustomDataSecretKey = "customData"
+			}),
+			newProviderSecretWith("customData", `{"role":"control-plane","agent":{"token":"secret"}}`),
+			`{"role":"control-plane","agent":{"url":"https://example.com","token":"secret"},"topology":{"metro":"ny"}}`),
+	)
+
+	table.DescribeTable("should reject invalid customdata",
+		func(spec []byte, secret *corev1.Secret) {
+			plugin, err := createMachine(spec, secret)
+			Expect(err).To(HaveOccurred())
+			Expect(plugin.Devices).To(BeEmpty())
+		},
+		table.Entry("no object in the provider spec",
+			newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.CustomData = json.RawMessage(`"worker"`) }),
+			newProviderSecret()),
+		table.Entry("missing secret key", fromSecret, newProviderSecret()),
+		table.Entry("no object in the secret", fromSecret, newProviderSecretWith("customData", `["secret"]`)),
+		table.Entry("invalid JSON in the secret", fromSecret, newProviderSecretWith("customData", `{"token":`)),
+		table.Entry("topology in the secret", fromSecret, newProviderSecretWith("customData", `{"topology":{"metro":"ams"}}`)),
+		table.Entry("customdata too long with the topology", fromSecret,
+			newProviderSecretWith("customData", `{"padding":"`+strings.Repeat("x", validation.CustomDataMaxLength-len(`{"padding":""}`))+`"}`)),
+	)
+})
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// deviceCacheKey identifies the device listing of a project. The listings are cached per API token, so that
+// a token never sees the devices listed with another one.
+type deviceCacheKey struct {
+	token, projectID string
+}
+
+func newDeviceCacheKey(secret *corev1.Secret, projectID string) deviceCacheKey {
+	return deviceCacheKey{token: spi.GetAPIKey(secret), projectID: projectID}
+}
+
+type deviceCacheEntry struct {
+	devices []metalv1.Device
+	listed  time.Time
+}
+
+// deviceCache caches the device listings of the projects for a short time, so that the MachineClasses sharing
+// a project share a single listing. It is invalidated by the creations and deletions of the provider.
+type deviceCache struct {
+	// ttl is the time a listing is served from the cache, 0 disables the cache
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[deviceCacheKey]*deviceCacheEntry
+}
+
+func newDeviceCache(ttl time.Duration) *deviceCache {
+	return &deviceCache{
+		ttl:     ttl,
+		entries: make(map[deviceCacheKey]*deviceCacheEntry),
+	}
+}
+
+// list returns the devices of the project, from the cache if the cached listing is recent enough
+func (c *deviceCache) list(ctx context.Context, svc spi.MetalDeviceService, key deviceCacheKey) ([]metalv1.Device, error) {
+	if devices, ok := c.cached(key); ok {
+		metrics.DeviceListingCacheRequests.WithLabelValues("hit").Inc()
+		return devices, nil
+	}
+	if c.ttl > 0 {
+		metrics.DeviceListingCacheRequests.WithLabelValues("miss").Inc()
+	}
+
+	deviceList, _, err := svc.FindProjectDevices(ctx, key.projectID)
+	if err != nil {
+		return nil, err
+	}
+	if c.ttl > 0 {
+		c.mu.Lock()
+		c.entries[key] = &deviceCacheEntry{devices: deviceList.Devices, listed: time.Now()}
+		c.mu.Unlock()
+	}
+	return deviceList.Devices, nil
+}
+
+// device returns the device from the cached listing of the project, if the listing is recent enough and contains it
+func (c *deviceCache) device(key deviceCacheKey, deviceID string) (*metalv1.Device, bool) {
+	if c.ttl == 0 {
+		return nil, false
+	}
+	devices, _ := c.cached(key)
+	for i := range devices {
+		if devices[i].GetId() == deviceID {
+			metrics.DeviceListingCacheRequests.WithLabelValues("hit").Inc()
+			return &devices[i], true
+		}
+	}
+	metrics.DeviceListingCacheRequests.WithLabelValues("miss").Inc()
+	return nil, false
+}
+
+func (c *deviceCache) cached(key deviceCacheKey) ([]metalv1.Device, bool) {
+	if c.ttl == 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.listed) >= c.ttl {
+		return nil, false
+	}
+	return entry.devices, true
+}
+
+// invalidate drops the cached listing of the project, e.g. after a device was created in it
+func (c *deviceCache) invalidate(key deviceCacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// forget drops the cached listings containing the device, e.g. after it was deleted
+func (c *deviceCache) forget(deviceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		for i := range entry.devices {
+			if entry.devices[i].GetId() == deviceID {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
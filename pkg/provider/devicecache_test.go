@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("DeviceListingCache", func() {
+	var (
+		ctx = context.Background()
+		p   driver.Driver
+	)
+	providerSecret := newProviderSecret()
+	providerSpec := newProviderSpec(nil)
+	apiRequests := func(operation string) float64 {
+		return testutil.ToFloat64(metrics.APIRequests.WithLabelValues(operation))
+	}
+	listMachines := func(machineClassName string) map[string]string {
+		resp, err := p.ListMachines(ctx, &driver.ListMachinesRequest{
+			MachineClass: setName(newMachineClass(providerSpec), machineClassName),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return resp.MachineList
+	}
+	createMachine := func() string {
+		resp, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      newMachine(0),
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return resp.ProviderID
+	}
+
+	BeforeEach(func() {
+		p = provider.NewProvider(spi.NewInstrumentedSessionProvider(&mock.PluginSPIImpl{}),
+			provider.WithDeviceListingCacheTTL(time.Hour))
+	})
+
+	It("should share the listing of a project between MachineClasses", func() {
+		listings := apiRequests("FindProjectDevices")
+		hits := testutil.ToFloat64(metrics.DeviceListingCacheRequests.WithLabelValues("hit"))
+
+		listMachines("eqx-mc-a")
+		listMachines("eqx-mc-b")
+
+		Expect(apiRequests("FindProjectDevices")).To(Equal(listings + 1))
+		Expect(testutil.ToFloat64(metrics.DeviceListingCacheRequests.WithLabelValues("hit"))).To(Equal(hits + 1))
+	})
+
+	It("should list again after a device was created or deleted", func() {
+		listMachines("eqx-mc")
+		providerID := createMachine()
+		Expect(listMachines("eqx-mc")).To(HaveKey(providerID))
+
+		machine := newMachine(0)
+		machine.Spec.ProviderID = providerID
+		_, err := p.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listMachines("eqx-mc")).NotTo(HaveKey(providerID))
+	})
+
+	It("should serve the status of listed devices from the cache", func() {
+		providerID := createMachine()
+		listMachines("eqx-mc")
+		lookups := apiRequests("FindDeviceByID")
+
+		machine := newMachine(0)
+		machine.Spec.ProviderID = providerID
+		resp, err := p.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.ProviderID).To(Equal(providerID))
+		Expect(apiRequests("FindDeviceByID")).To(Equal(lookups))
+	})
+
+	It("should list every time without ttl", func() {
+		p = provider.NewProvider(spi.NewInstrumentedSessionProvider(&mock.PluginSPIImpl{}))
+		listings := apiRequests("FindProjectDevices")
+
+		listMachines("eqx-mc-a")
+		listMachines("eqx-mc-b")
+
+		Expect(apiRequests("FindProjectDevices")).To(Equal(listings + 2))
+	})
+})
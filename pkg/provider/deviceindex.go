@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// indexStaleIntervals is the number of intervals after which the index of a project is no longer used,
+	// e.g. because listing its devices keeps failing
+	indexStaleIntervals = 3
+	// indexIdleIntervals is the number of intervals after which a project no machine was requested for
+	// is no longer listed
+	indexIdleIntervals = 10
+)
+
+// deviceIndex periodically lists the devices of the projects the provider manages machines in, so that
+// status checks are served from a single listing per project instead of one lookup per device.
+// The Equinix Metal API supports neither ETags nor If-Modified-Since for device listings, so every listing
+// is a full one.
+type deviceIndex struct {
+	// interval is the interval between the listings of a project, 0 disables the index
+	interval time.Duration
+
+	mu       sync.Mutex
+	projects map[deviceCacheKey]*indexedProject
+}
+
+type indexedProject struct {
+	// secret is the secret of the latest request for the project, it is used for listing its devices
+	secret *corev1.Secret
+	// requested is the time of the latest request for the project
+	requested time.Time
+	// listed is the time of the latest successful listing
+	listed  time.Time
+	devices map[string]metalv1.Device
+}
+
+func newDeviceIndex(interval time.Duration) *deviceIndex {
+	return &deviceIndex{
+		interval: interval,
+		projects: make(map[deviceCacheKey]*indexedProject),
+	}
+}
+
+// watch adds the project to the index or marks it as requested again
+func (i *deviceIndex) watch(key deviceCacheKey, secret *corev1.Secret) {
+	if i.interval == 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	project, ok := i.projects[key]
+	if !ok {
+		project = &indexedProject{}
+		i.projects[key] = project
+	}
+	project.secret = secret
+	project.requested = time.Now()
+}
+
+// device returns the device from the index of the project, if the index is recent enough and contains it
+func (i *deviceIndex) device(key deviceCacheKey, deviceID string) (*metalv1.Device, bool) {
+	if i.interval == 0 {
+		return nil, false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	project, ok := i.projects[key]
+	if ok && time.Since(project.listed) < indexStaleIntervals*i.interval {
+		if device, ok := project.devices[deviceID]; ok {
+			metrics.DeviceIndexLookups.WithLabelValues("hit").Inc()
+			return &device, true
+		}
+	}
+	metrics.DeviceIndexLookups.WithLabelValues("miss").Inc()
+	return nil, false
+}
+
+// forget removes the device from the index, e.g. after it was deleted
+func (i *deviceIndex) forget(deviceID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, project := range i.projects {
+		delete(project.devices, deviceID)
+	}
+}
+
+// run lists the devices of the watched projects once per interval until the context is done
+func (i *deviceIndex) run(ctx context.Context, createSVC func(*corev1.Secret) (spi.MetalDeviceService, error)) {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			i.refresh(ctx, createSVC)
+		}
+	}
+}
+
+// refresh lists the devices of all watched projects and drops the projects that were not requested for a while
+func (i *deviceIndex) refresh(ctx context.Context, createSVC func(*corev1.Secret) (spi.MetalDeviceService, error)) {
+	i.mu.Lock()
+	projects := make(map[deviceCacheKey]*corev1.Secret, len(i.projects))
+	for key, project := range i.projects {
+		if time.Since(project.requested) >= indexIdleIntervals*i.interval {
+			delete(i.projects, key)
+			continue
+		}
+		projects[key] = project.secret
+	}
+	i.mu.Unlock()
+
+	for key, secret := range projects {
+		logger := klog.FromContext(ctx).WithValues(logging.KeyProjectID, key.projectID)
+		svc, err := createSVC(secret)
+		if err != nil {
+			logger.Error(err, "Could not index devices")
+			continue
+		}
+		deviceList, _, err := svc.FindProjectDevices(ctx, key.projectID)
+		if err != nil {
+			logger.Error(err, "Could not index devices")
+			continue
+		}
+		devices := make(map[string]metalv1.Device, len(deviceList.Devices))
+		for _, device := range deviceList.Devices {
+			devices[device.GetId()] = device
+		}
+
+		logger.V(4).Info("Indexed devices", "devices", len(devices))
+
+		i.mu.Lock()
+		if project, ok := i.projects[key]; ok {
+			project.devices = devices
+			project.listed = time.Now()
+		}
+		i.mu.Unlock()
+	}
+}
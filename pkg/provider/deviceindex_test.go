@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("DeviceIndex", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		p      driver.Driver
+	)
+	providerSecret := newProviderSecret()
+	providerSpec := newProviderSpec(nil)
+	lookups := func() float64 {
+		return testutil.ToFloat64(metrics.APIRequests.WithLabelValues("FindDeviceByID"))
+	}
+	indexHits := func() float64 {
+		return testutil.ToFloat64(metrics.DeviceIndexLookups.WithLabelValues("hit"))
+	}
+	machineWithProviderID := func(providerID string) *v1alpha1.Machine {
+		machine := newMachine(0)
+		machine.Spec.ProviderID = providerID
+		return machine
+	}
+	statusCode := func(err error) codes.Code {
+		s, ok := status.FromError(err)
+		Expect(ok).To(BeTrue(), "no status error: %v", err)
+		return s.Code()
+	}
+	getMachineStatus := func(machine *v1alpha1.Machine) error {
+		_, err := p.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		return err
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		p = provider.NewProvider(spi.NewInstrumentedSessionProvider(&mock.PluginSPIImpl{}),
+			provider.WithDeviceIndex(ctx, 10*time.Millisecond))
+	})
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should serve the status of indexed devices from the index", func() {
+		resp, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      newMachine(0),
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		machine := machineWithProviderID(resp.ProviderID)
+
+		hits := indexHits()
+		Eventually(func() float64 {
+			Expect(getMachineStatus(machine)).To(Succeed())
+			return indexHits()
+		}).Should(BeNumerically(">", hits))
+
+		before := lookups()
+		Expect(getMachineStatus(machine)).To(Succeed())
+		Expect(lookups()).To(Equal(before))
+	})
+
+	It("should look up devices that were not indexed yet or deleted", func() {
+		resp, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      newMachine(0),
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		machine := machineWithProviderID(resp.ProviderID)
+		hits := indexHits()
+		Eventually(func() float64 {
+			Expect(getMachineStatus(machine)).To(Succeed())
+			return indexHits()
+		}).Should(BeNumerically(">", hits))
+
+		before := lookups()
+		err = getMachineStatus(machineWithProviderID("equinixmetal://ny/unknown"))
+		Expect(statusCode(err)).To(Equal(codes.NotFound))
+		Expect(lookups()).To(Equal(before + 1))
+
+		_, err = p.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		err = getMachineStatus(machine)
+		Expect(statusCode(err)).To(Equal(codes.NotFound))
+	})
+})
@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("IPXE", func() {
+	const script = "#!ipxe\nchain https://example.com/boot.ipxe"
+	scriptURL := "https://example.com/boot.ipxe"
+	withScript := newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+		spec.OS = ""
+		spec.IPXEScriptSecretKey = "ipxeScript"
+	})
+
+	table.DescribeTable("#CreateMachine",
+		func(spec []byte, secret *corev1.Secret, os string, ipxeScriptURL *string, userData string, alwaysPXE bool) {
+			plugin, err := createMachine(spec, secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plugin.Devices).To(HaveLen(1))
+			device := plugin.Devices[0]
+			Expect(device.OperatingSystem.GetName()).To(Equal(os))
+			Expect(device.IpxeScriptUrl).To(Equal(ipxeScriptURL))
+			Expect(device.GetUserdata()).To(Equal(userData))
+			Expect(device.GetAlwaysPxe()).To(Equal(alwaysPXE))
+		},
+		table.Entry("OS only", newProviderSpec(nil), newProviderSecret(),
+			"alpine_3", nil, "dummy-user-data", false),
+		table.Entry("script URL overrides OS",
+			newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.IPXEScriptURL = &scriptURL }), newProviderSecret(),
+			api.OSCustomIPXE, &scriptURL, "dummy-user-data", false),
+		table.Entry("script URL without OS and always PXE", newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = ""
+			spec.IPXEScriptURL = &scriptURL
+			spec.AlwaysPXE = true
+		}), newProviderSecret(),
+			api.OSCustomIPXE, &scriptURL, "dummy-user-data", true),
+		table.Entry("inline script overrides OS", newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.IPXEScriptSecretKey = "ipxeScript"
+		}), newProviderSecretWith("ipxeScript", script),
+			api.OSCustomIPXE, nil, script, false),
+		table.Entry("inline script without OS and always PXE", newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = ""
+			spec.IPXEScriptSecretKey = "ipxeScript"
+			spec.AlwaysPXE = true
+		}), newProviderSecretWith("ipxeScript", script),
+			api.OSCustomIPXE, nil, script, true),
+		table.Entry("custom iPXE OS with always PXE", newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = api.OSCustomIPXE
+			spec.AlwaysPXE = true
+		}), newProviderSecret(),
+			api.OSCustomIPXE, nil, "dummy-user-data", true),
+	)
+
+	table.DescribeTable("#CreateMachine with an invalid iPXE configuration",
+		func(spec []byte, secret *corev1.Secret) {
+			plugin, err := createMachine(spec, secret)
+			Expect(err).To(HaveOccurred())
+			Expect(plugin.Devices).To(BeEmpty())
+		},
+		table.Entry("missing inline script", withScript, newProviderSecret()),
+		table.Entry("inline script without #!ipxe", withScript, newProviderSecretWith("ipxeScript", "#!/bin/sh")),
+		table.Entry("script URL and inline script", newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.OS = ""
+			spec.IPXEScriptURL = &scriptURL
+			spec.IPXEScriptSecretKey = "ipxeScript"
+		}), newProviderSecretWith("ipxeScript", script)),
+		table.Entry("always PXE without script", newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.AlwaysPXE = true }),
+			newProviderSecret()),
+	)
+})
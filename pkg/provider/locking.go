@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+)
+
+// unlockForDeletion unlocks the locked device of the machine if its deletion has been approved with
+// api.AnnotationApproveDeletion. Otherwise, it returns a FailedPrecondition error, so that the device is kept.
+func unlockForDeletion(ctx context.Context, svc spi.MetalDeviceService, machine *v1alpha1.Machine, deviceID string) error {
+	logger := klog.FromContext(ctx)
+	if machine.Annotations[api.AnnotationApproveDeletion] != "true" {
+		logger.Info("Device is locked and its deletion has not been approved", "annotation", api.AnnotationApproveDeletion)
+		return status.Error(codes.FailedPrecondition, fmt.Sprintf(
+			"Device %s is locked, annotate the machine with %s=true to approve its deletion", deviceID, api.AnnotationApproveDeletion))
+	}
+	if _, _, err := svc.UpdateDevice(ctx, deviceID, metalv1.DeviceUpdateInput{Locked: metalv1.PtrBool(false)}); err != nil {
+		logger.Error(err, "Could not unlock device")
+		return status.Error(codes.Unknown, fmt.Sprintf("Could not unlock device %s: %v", deviceID, err))
+	}
+	logger.V(2).Info("Unlocked device for its approved deletion")
+	return nil
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DeviceLocking", func() {
+	var (
+		ctx     = context.Background()
+		plugin  *mock.PluginSPIImpl
+		p       driver.Driver
+		machine *v1alpha1.Machine
+	)
+	providerSecret := newProviderSecret()
+	newMachineClassSpec := func(strategy string) []byte {
+		return newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ReservationIDs = []string{"res-1"}
+			spec.ReplacementStrategy = strategy
+			spec.Locked = true
+		})
+	}
+	createMachine := func(spec []byte) {
+		resp, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(spec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		machine.Spec.ProviderID = resp.ProviderID
+		Expect(plugin.Devices[0].GetLocked()).To(BeTrue())
+	}
+	deleteMachine := func(spec []byte) error {
+		_, err := p.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(spec),
+			Secret:       providerSecret,
+		})
+		return err
+	}
+	expectFailedPrecondition := func(err error) {
+		Expect(err).To(HaveOccurred())
+		s, _ := status.FromError(err)
+		Expect(s.Code()).To(Equal(codes.FailedPrecondition))
+	}
+
+	BeforeEach(func() {
+		plugin = &mock.PluginSPIImpl{
+			HardwareReservations: []metalv1.HardwareReservation{{Id: metalv1.PtrString("res-1")}},
+		}
+		p = provider.NewProvider(plugin)
+		machine = newMachine(1)
+	})
+
+	It("should keep locked devices without deletion approval", func() {
+		spec := newMachineClassSpec("")
+		createMachine(spec)
+		expectFailedPrecondition(deleteMachine(spec))
+		Expect(plugin.Devices).To(HaveLen(1))
+		Expect(plugin.Devices[0].GetLocked()).To(BeTrue())
+	})
+
+	It("should unlock and delete locked devices with deletion approval", func() {
+		spec := newMachineClassSpec("")
+		createMachine(spec)
+		machine.Annotations = map[string]string{api.AnnotationApproveDeletion: "true"}
+		Expect(deleteMachine(spec)).To(Succeed())
+		Expect(plugin.Devices).To(BeEmpty())
+	})
+
+	It("should only park locked devices with deletion approval", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall)
+		createMachine(spec)
+		expectFailedPrecondition(deleteMachine(spec))
+		Expect(plugin.Actions).To(BeEmpty())
+
+		machine.Annotations = map[string]string{api.AnnotationApproveDeletion: "true"}
+		Expect(deleteMachine(spec)).To(Succeed())
+		Expect(plugin.Devices).To(HaveLen(1))
+		Expect(plugin.Devices[0].GetLocked()).To(BeFalse())
+		Expect(plugin.Actions).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/klog/v2"
+)
+
+var _ = Describe("Logging", func() {
+	var (
+		ctx      context.Context
+		messages []string
+	)
+	providerSecret := newProviderSecret()
+	providerSpec := newProviderSpec(nil)
+	messageWith := func(msg string, substrings ...string) string {
+	outer:
+		for _, message := range messages {
+			for _, substring := range append(substrings, `"msg"="`+msg+`"`) {
+				if !strings.Contains(message, substring) {
+					continue outer
+				}
+			}
+			return message
+		}
+		Fail("no log message " + msg)
+		return ""
+	}
+
+	BeforeEach(func() {
+		messages = nil
+		logger := funcr.New(func(prefix, args string) {
+			messages = append(messages, args)
+		}, funcr.Options{Verbosity: 4})
+		ctx = klog.NewContext(context.Background(), logger)
+	})
+
+	It("should log the machine, project and device of the driver and device service calls", func() {
+		p := provider.NewProvider(spi.NewInstrumentedSessionProvider(&mock.PluginSPIImpl{}))
+		resp, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      newMachine(0),
+			MachineClass: setName(newMachineClass(providerSpec), "eqx-mc"),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		deviceID := resp.ProviderID[strings.LastIndex(resp.ProviderID, "/")+1:]
+
+		Expect(messageWith("Machine creation request has been received")).To(And(
+			ContainSubstring(`"machine"="machine-0"`),
+			ContainSubstring(`"machineClass"="eqx-mc"`),
+		))
+		Expect(messageWith("Equinix Metal API call succeeded", `"operation"="CreateDevice"`)).To(And(
+			ContainSubstring(`"machine"="machine-0"`),
+			ContainSubstring(`"projectID"="abcdefg"`),
+			ContainSubstring(`"metro"="ny"`),
+		))
+		Expect(messageWith("Machine creation request has been processed")).To(ContainSubstring(`"deviceID"="` + deviceID + `"`))
+	})
+
+	It("should log the failed device service calls with the device", func() {
+		p := provider.NewProvider(spi.NewInstrumentedSessionProvider(&mock.PluginSPIImpl{}))
+		_, err := p.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+			Machine:      newMachine(1),
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).To(HaveOccurred())
+
+		Expect(messageWith("Equinix Metal API call failed")).To(And(
+			ContainSubstring(`"operation"="FindDeviceByID"`),
+			ContainSubstring(`"deviceID"="000001"`),
+			ContainSubstring(`"err"=`),
+		))
+	})
+})
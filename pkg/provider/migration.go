@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	apiv1alpha1 "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/v1alpha1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/tracing"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+// facilityMetros maps the facilities of legacy PacketMachineClasses to the metro they are located in
+var facilityMetros = map[string]string{
+	"am6":  "am",
+	"ams1": "am",
+	"at4":  "at",
+	"ch3":  "ch",
+	"da6":  "da",
+	"da11": "da",
+	"dfw2": "da",
+	"dc10": "dc",
+	"dc13": "dc",
+	"iad2": "dc",
+	"ewr1": "ny",
+	"ny5":  "ny",
+	"ny7":  "ny",
+	"fr2":  "fr",
+	"fr8":  "fr",
+	"fra2": "fr",
+	"hk2":  "hk",
+	"la4":  "la",
+	"ld7":  "ld",
+	"md2":  "md",
+	"mad2": "md",
+	"ml1":  "ml",
+	"mt1":  "mt",
+	"nrt1": "ty",
+	"ty11": "ty",
+	"pa4":  "pa",
+	"par2": "pa",
+	"se4":  "se",
+	"sg1":  "sg",
+	"sg4":  "sg",
+	"sg5":  "sg",
+	"sin3": "sg",
+	"sjc1": "sv",
+	"sv15": "sv",
+	"sv16": "sv",
+	"sl1":  "sl",
+	"sp4":  "sp",
+	"sy4":  "sy",
+	"sy5":  "sy",
+	"syd2": "sy",
+	"tr2":  "tr",
+	"yyz1": "tr",
+}
+
+// GenerateMachineClassForMigration converts a legacy PacketMachineClass of the in-tree Packet driver into a MachineClass
+// OPTIONAL METHOD
+//
+// REQUEST PARAMETERS (driver.GenerateMachineClassForMigrationRequest)
+// ProviderSpecificMachineClass    interface{}              The *api.PacketMachineClass to be migrated
+// MachineClass                    *v1alpha1.MachineClass   MachineClass object that is filled up with the migrated fields
+// ClassSpec                       *v1alpha1.ClassSpec      ClassSpec of the machines, its kind must be PacketMachineClass
+//
+// RESPONSE PARAMETERS (driver.GenerateMachineClassForMigrationResponse)
+// NONE
+func (p *Provider) GenerateMachineClassForMigration(ctx context.Context, req *driver.GenerateMachineClassForMigrationRequest) (_ *driver.GenerateMachineClassForMigrationResponse, err error) {
+	// Log messages to track start and end of request
+	logger := klog.FromContext(ctx)
+	if req.ClassSpec != nil {
+		logger = logger.WithValues(logging.KeyMachineClass, req.ClassSpec.Name, "kind", req.ClassSpec.Kind)
+	}
+	logger.V(2).Info("MigrateMachineClass request has been received")
+	defer logger.V(2).Info("MigrateMachineClass request has been processed")
+	_, span := tracing.Start(ctx, "GenerateMachineClassForMigration")
+	defer func() { tracing.End(span, err) }()
+
+	// Check if incoming CR is valid CR for migration
+	// In this case, the MachineClassKind to be migrated from is PacketMachineClass
+	if req.ClassSpec == nil || req.ClassSpec.Kind != PacketMachineClassKind {
+		return nil, status.Error(codes.Internal, "Migration cannot be done for this machineClass kind")
+	}
+	packetMachineClass, ok := req.ProviderSpecificMachineClass.(*api.PacketMachineClass)
+	if !ok {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("Migration expects a %s, but got %T", PacketMachineClassKind, req.ProviderSpecificMachineClass))
+	}
+
+	if err := fillUpMachineClass(packetMachineClass, req.MachineClass); err != nil {
+		return nil, err
+	}
+	return &driver.GenerateMachineClassForMigrationResponse{}, nil
+}
+
+// fillUpMachineClass copies over the fields of the PacketMachineClass to the MachineClass
+func fillUpMachineClass(packetMachineClass *api.PacketMachineClass, machineClass *v1alpha1.MachineClass) error {
+	spec := packetMachineClass.Spec
+
+	metros, err := metrosOfFacilities(spec.Facility)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	providerSpec := &api.EquinixMetalProviderSpec{
+		APIVersion:     api.V1alpha1,
+		Metro:          metros[0],
+		FallbackMetros: metros[1:],
+		MachineType:    spec.MachineType,
+		BillingCycle:   spec.BillingCycle,
+		OS:             spec.OS,
+		ProjectID:      spec.ProjectID,
+		Tags:           spec.Tags,
+		SSHKeys:        spec.SSHKeys,
+		UserData:       spec.UserData,
+		// nodes of the in-tree driver are registered with the legacy ProviderIDs
+		ProviderIDFormat: api.ProviderIDFormatPacket,
+	}
+	versioned := &apiv1alpha1.EquinixMetalProviderSpec{}
+	versioned.ConvertFrom(providerSpec)
+	raw, err := json.Marshal(versioned)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	machineClass.Name = packetMachineClass.Name
+	machineClass.Labels = packetMachineClass.Labels
+	machineClass.Annotations = packetMachineClass.Annotations
+	machineClass.Finalizers = packetMachineClass.Finalizers
+	machineClass.ProviderSpec = runtime.RawExtension{Raw: raw}
+	machineClass.SecretRef = spec.SecretRef
+	machineClass.CredentialsSecretRef = spec.CredentialsSecretRef
+	machineClass.Provider = ProviderEquinixMetal
+
+	return nil
+}
+
+// metrosOfFacilities returns the metros of the facilities in order, without duplicates
+func metrosOfFacilities(facilities []string) ([]string, error) {
+	var (
+		metros []string
+		seen   = make(map[string]bool)
+	)
+	for _, facility := range facilities {
+		metro, ok := facilityMetros[facility]
+		if !ok {
+			return nil, fmt.Errorf("facility %q cannot be mapped to a metro", facility)
+		}
+		if !seen[metro] {
+			seen[metro] = true
+			metros = append(metros, metro)
+		}
+	}
+	if len(metros) == 0 {
+		return nil, fmt.Errorf("at least one facility is required to determine the metro")
+	}
+	return metros, nil
+}
@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Migration", func() {
+	newPacketMachineClass := func(modify func(spec *api.PacketMachineClassSpec)) *api.PacketMachineClass {
+		machineClass := &api.PacketMachineClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "packet-mc",
+				Namespace:   testNamespace,
+				Labels:      map[string]string{"label": "value"},
+				Annotations: map[string]string{"annotation": "value"},
+				Finalizers:  []string{"machine.sapcloud.io/machine-controller-manager"},
+			},
+			Spec: api.PacketMachineClassSpec{
+				Facility:     []string{"ewr1"},
+				MachineType:  "c3.small.x86",
+				BillingCycle: "hourly",
+				OS:           "flatcar_stable",
+				ProjectID:    "abcdefg",
+				Tags:         []string{"kubernetes.io/cluster/shoot-test: 1", "kubernetes.io/role/test: 1"},
+				SSHKeys:      []string{"key-1"},
+				SecretRef:    &corev1.SecretReference{Name: "userdata", Namespace: testNamespace},
+				CredentialsSecretRef: &corev1.SecretReference{
+					Name:      "credentials",
+					Namespace: testNamespace,
+				},
+			},
+		}
+		if modify != nil {
+			modify(&machineClass.Spec)
+		}
+		return machineClass
+	}
+	packetClassSpec := &v1alpha1.ClassSpec{Kind: provider.PacketMachineClassKind, Name: "packet-mc"}
+
+	migrate := func(req *driver.GenerateMachineClassForMigrationRequest) error {
+		p := provider.NewProvider(&mock.PluginSPIImpl{}).(*provider.Provider)
+		_, err := p.GenerateMachineClassForMigration(context.Background(), req)
+		return err
+	}
+
+	DescribeTable("#GenerateMachineClassForMigration provider spec",
+		func(packetMachineClass *api.PacketMachineClass, expected api.EquinixMetalProviderSpec) {
+			machineClass := &v1alpha1.MachineClass{}
+			Expect(migrate(&driver.GenerateMachineClassForMigrationRequest{
+				ProviderSpecificMachineClass: packetMachineClass,
+				MachineClass:                 machineClass,
+				ClassSpec:                    packetClassSpec,
+			})).To(Succeed())
+
+			providerSpec := api.EquinixMetalProviderSpec{}
+			Expect(json.Unmarshal(machineClass.ProviderSpec.Raw, &providerSpec)).To(Succeed())
+			Expect(providerSpec).To(Equal(expected))
+		},
+		Entry("single facility", newPacketMachineClass(nil), api.EquinixMetalProviderSpec{
+			APIVersion:       api.V1alpha1,
+			Metro:            "ny",
+			MachineType:      "c3.small.x86",
+			BillingCycle:     "hourly",
+			OS:               "flatcar_stable",
+			ProjectID:        "abcdefg",
+			Tags:             []string{"kubernetes.io/cluster/shoot-test: 1", "kubernetes.io/role/test: 1"},
+			SSHKeys:          []string{"key-1"},
+			ProviderIDFormat: api.ProviderIDFormatPacket,
+		}),
+		Entry("facilities in several metros", newPacketMachineClass(func(spec *api.PacketMachineClassSpec) {
+			spec.Facility = []string{"ewr1", "ny5", "dfw2", "sjc1"}
+			spec.UserData = "#!/bin/sh"
+			spec.SSHKeys = nil
+			spec.Tags = nil
+		}), api.EquinixMetalProviderSpec{
+			APIVersion:       api.V1alpha1,
+			Metro:            "ny",
+			FallbackMetros:   []string{"da", "sv"},
+			MachineType:      "c3.small.x86",
+			BillingCycle:     "hourly",
+			OS:               "flatcar_stable",
+			ProjectID:        "abcdefg",
+			UserData:         "#!/bin/sh",
+			ProviderIDFormat: api.ProviderIDFormatPacket,
+		}),
+	)
+
+	It("should copy the metadata and secret references", func() {
+		machineClass := &v1alpha1.MachineClass{}
+		Expect(migrate(&driver.GenerateMachineClassForMigrationRequest{
+			ProviderSpecificMachineClass: newPacketMachineClass(nil),
+			MachineClass:                 machineClass,
+			ClassSpec:                    packetClassSpec,
+		})).To(Succeed())
+
+		Expect(machineClass.Name).To(Equal("packet-mc"))
+		Expect(machineClass.Labels).To(Equal(map[string]string{"label": "value"}))
+		Expect(machineClass.Annotations).To(Equal(map[string]string{"annotation": "value"}))
+		Expect(machineClass.Finalizers).To(Equal([]string{"machine.sapcloud.io/machine-controller-manager"}))
+		Expect(machineClass.SecretRef).To(Equal(&corev1.SecretReference{Name: "userdata", Namespace: testNamespace}))
+		Expect(machineClass.CredentialsSecretRef).To(Equal(&corev1.SecretReference{Name: "credentials", Namespace: testNamespace}))
+		Expect(machineClass.Provider).To(Equal(provider.ProviderEquinixMetal))
+	})
+
+	DescribeTable("#GenerateMachineClassForMigration errors",
+		func(req *driver.GenerateMachineClassForMigrationRequest, errMessage string) {
+			err := migrate(req)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(errMessage))
+		},
+		Entry("wrong kind", &driver.GenerateMachineClassForMigrationRequest{
+			ProviderSpecificMachineClass: newPacketMachineClass(nil),
+			MachineClass:                 &v1alpha1.MachineClass{},
+			ClassSpec:                    &v1alpha1.ClassSpec{Kind: "AWSMachineClass"},
+		}, "machine codes error: code = [Internal] message = [Migration cannot be done for this machineClass kind]"),
+		Entry("wrong type", &driver.GenerateMachineClassForMigrationRequest{
+			ProviderSpecificMachineClass: &v1alpha1.MachineClass{},
+			MachineClass:                 &v1alpha1.MachineClass{},
+			ClassSpec:                    packetClassSpec,
+		}, "machine codes error: code = [Internal] message = [Migration expects a PacketMachineClass, but got *v1alpha1.MachineClass]"),
+		Entry("unknown facility", &driver.GenerateMachineClassForMigrationRequest{
+			ProviderSpecificMachineClass: newPacketMachineClass(func(spec *api.PacketMachineClassSpec) {
+				spec.Facility = []string{"xyz1"}
+			}),
+			MachineClass: &v1alpha1.MachineClass{},
+			ClassSpec:    packetClassSpec,
+		}, "machine codes error: code = [InvalidArgument] message = [facility \"xyz1\" cannot be mapped to a metro]"),
+		Entry("no facility", &driver.GenerateMachineClassForMigrationRequest{
+			ProviderSpecificMachineClass: newPacketMachineClass(func(spec *api.PacketMachineClassSpec) {
+				spec.Facility = nil
+			}),
+			MachineClass: &v1alpha1.MachineClass{},
+			ClassSpec:    packetClassSpec,
+		}, "machine codes error: code = [InvalidArgument] message = [at least one facility is required to determine the metro]"),
+	)
+
+	It("should keep the legacy ProviderIDs of migrated machines", func() {
+		plugin := &mock.PluginSPIImpl{}
+		p := provider.NewProvider(plugin)
+		ctx := context.Background()
+		machineClass := &v1alpha1.MachineClass{}
+		Expect(migrate(&driver.GenerateMachineClassForMigrationRequest{
+			ProviderSpecificMachineClass: newPacketMachineClass(nil),
+			MachineClass:                 machineClass,
+			ClassSpec:                    packetClassSpec,
+		})).To(Succeed())
+		secret := newProviderSecret()
+
+		created, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      newMachine(-1),
+			MachineClass: machineClass,
+			Secret:       secret,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.ProviderID).To(Equal("packet://000001"))
+
+		machine := newMachine(-1)
+		machine.Spec.ProviderID = created.ProviderID
+		status, err := p.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+			Machine:      machine,
+			MachineClass: machineClass,
+			Secret:       secret,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status.ProviderID).To(Equal("packet://000001"))
+	})
+})
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"math/rand"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+)
+
+// placeProjects returns the IDs of the projects of the provider spec in the order they are tried for a new device.
+// The weighted project placement draws the projects randomly by weight, projects without weight come last.
+func placeProjects(providerSpec *api.EquinixMetalProviderSpec) []string {
+	if providerSpec.ProjectPlacement != api.ProjectPlacementWeighted {
+		return providerSpec.ProjectIDs()
+	}
+
+	var (
+		remaining = append([]api.Project(nil), providerSpec.Projects...)
+		ids       = make([]string, 0, len(remaining))
+	)
+	for len(remaining) > 0 {
+		total := 0
+		for _, project := range remaining {
+			total += project.Weight
+		}
+		if total == 0 {
+			break
+		}
+		n := rand.Intn(total)
+		for i, project := range remaining {
+			if n < project.Weight {
+				ids = append(ids, project.ID)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			n -= project.Weight
+		}
+	}
+	for _, project := range remaining {
+		ids = append(ids, project.ID)
+	}
+	return ids
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Projects", func() {
+	providerSecret := newProviderSecret()
+	orderedSpec := newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+		spec.Projects = []api.Project{{ID: "p1"}, {ID: "p2"}}
+		spec.ProjectPlacement = api.ProjectPlacementOrdered
+		spec.ProviderIDFormat = api.ProviderIDFormatProject
+		spec.ProjectID = ""
+	})
+	newDevice := func(id, projectID, hostname string) metalv1.Device {
+		metro := "ny"
+		return metalv1.Device{
+			Id:       &id,
+			Hostname: &hostname,
+			Metro:    &metalv1.DeviceMetro{Code: &metro},
+			Project:  &metalv1.Project{Id: &projectID},
+			Tags:     []string{"kubernetes.io/cluster/shoot-test: 1", "kubernetes.io/role/test: 1"},
+		}
+	}
+
+	table.DescribeTable("should create the device in the first project with quota",
+		func(placement string, projects []api.Project, noQuota map[string]bool, expected string) {
+			plugin := &mock.PluginSPIImpl{NoQuota: noQuota}
+			resp, err := createMachineWith(plugin, newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+				spec.Projects = projects
+				spec.ProjectPlacement = placement
+				spec.ProviderIDFormat = api.ProviderIDFormatProject
+				spec.ProjectID = ""
+			}), providerSecret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plugin.Devices).To(HaveLen(1))
+			Expect(plugin.Devices[0].Project.GetId()).To(Equal(expected))
+			Expect(resp.ProviderID).To(Equal("equinixmetal://" + expected + "/ny/" + plugin.Devices[0].GetId()))
+		},
+		table.Entry("ordered", api.ProjectPlacementOrdered,
+			[]api.Project{{ID: "p1"}, {ID: "p2"}}, nil, "p1"),
+		table.Entry("ordered with fallback", api.ProjectPlacementOrdered,
+			[]api.Project{{ID: "p1"}, {ID: "p2"}}, map[string]bool{"p1": true}, "p2"),
+		table.Entry("weighted", api.ProjectPlacementWeighted,
+			[]api.Project{{ID: "p1"}, {ID: "p2", Weight: 1}}, nil, "p2"),
+		table.Entry("weighted with fallback to projects without weight", api.ProjectPlacementWeighted,
+			[]api.Project{{ID: "p1"}, {ID: "p2", Weight: 1}}, map[string]bool{"p2": true}, "p1"),
+	)
+
+	It("should fail if no project has quota", func() {
+		plugin := &mock.PluginSPIImpl{NoQuota: map[string]bool{"p1": true, "p2": true}}
+		_, err := createMachineWith(plugin, orderedSpec, providerSecret)
+		Expect(err).To(MatchError(ContainSubstring("project p2 has reached its device quota")))
+		Expect(plugin.Devices).To(BeEmpty())
+	})
+
+	It("should list the machines of all projects", func() {
+		plugin := &mock.PluginSPIImpl{
+			Devices: []metalv1.Device{
+				newDevice("d1", "p1", "machine-1"),
+				newDevice("d2", "p2", "machine-2"),
+				newDevice("d3", "p3", "machine-3"),
+			},
+		}
+		resp, err := provider.NewProvider(plugin).ListMachines(context.Background(), &driver.ListMachinesRequest{
+			MachineClass: newMachineClass(orderedSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.MachineList).To(Equal(map[string]string{
+			"equinixmetal://p1/ny/d1": "machine-1",
+			"equinixmetal://p2/ny/d2": "machine-2",
+		}))
+	})
+
+	It("should keep the project of an existing ProviderID", func() {
+		plugin := &mock.PluginSPIImpl{Devices: []metalv1.Device{newDevice("d2", "p2", "machine-1")}}
+		machine := newMachine(1)
+		machine.Spec.ProviderID = "equinixmetal://p2/ny/d2"
+		resp, err := provider.NewProvider(plugin).GetMachineStatus(context.Background(), &driver.GetMachineStatusRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(orderedSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.ProviderID).To(Equal("equinixmetal://p2/ny/d2"))
+	})
+})
@@ -7,6 +7,11 @@
 package provider
 
 import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/catalog"
 	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
 )
@@ -15,11 +20,95 @@ import (
 // It is used to implement the basic driver functionalities
 type Provider struct {
 	SPI spi.SessionProviderInterface
+
+	// catalogRefreshInterval is the minimum interval between refreshes of the plan catalog, 0 disables refreshing
+	catalogRefreshInterval time.Duration
+	// devices caches the device listings of the projects
+	devices *deviceCache
+	// index periodically lists the devices of the projects for the status checks
+	index *deviceIndex
+	// reservations periodically exports the utilisation of the hardware reservations of the MachineClasses
+	reservations *reservationInventory
+	// claimSettleDelay is the time a claim of a parked device has to persist before the device is reused
+	claimSettleDelay time.Duration
+
+	mu sync.Mutex
+	// claimed are the IDs of the parked devices claimed for new machines by this provider
+	claimed map[string]bool
+	// actions are the values of the action annotations performed by this provider by device ID
+	actions map[string]string
+	// topologies are the "<metro>/<facility>" topologies stamped on devices by this provider by device ID
+	topologies map[string]string
+	// catalog is the catalog of plans and operating systems provider specs are validated against
+	catalog *catalog.Catalog
+	// nextCatalogRefresh is the earliest time of the next catalog refresh
+	nextCatalogRefresh time.Time
+	// catalogRefreshFailures is the number of consecutive failed catalog refreshes
+	catalogRefreshFailures int
+}
+
+// Option configures optional behaviour of the provider
+type Option func(*Provider)
+
+// WithCatalogRefreshInterval enables refreshing the plan catalog from the Equinix Metal API at most once per interval
+func WithCatalogRefreshInterval(interval time.Duration) Option {
+	return func(p *Provider) {
+		p.catalogRefreshInterval = interval
+	}
+}
+
+// WithReservationInventory enables exporting the utilisation of the hardware reservations of the MachineClasses
+// listed by the provider once per interval until the context is done
+func WithReservationInventory(ctx context.Context, interval time.Duration) Option {
+	return func(p *Provider) {
+		p.reservations = newReservationInventory(interval)
+		if interval > 0 {
+			go p.reservations.run(ctx, p.createSVC)
+		}
+	}
+}
+
+// WithClaimSettleDelay sets the time a claim of a parked device has to persist before the device is reused
+func WithClaimSettleDelay(delay time.Duration) Option {
+	return func(p *Provider) {
+		p.claimSettleDelay = delay
+	}
+}
+
+// WithDeviceListingCacheTTL enables serving the device listings of a project from a cache shared by all
+// MachineClasses for the ttl
+func WithDeviceListingCacheTTL(ttl time.Duration) Option {
+	return func(p *Provider) {
+		p.devices = newDeviceCache(ttl)
+	}
+}
+
+// WithDeviceIndex enables listing the devices of the projects of the requested machines once per interval until
+// the context is done, so that the status of the machines is looked up in the listings
+func WithDeviceIndex(ctx context.Context, interval time.Duration) Option {
+	return func(p *Provider) {
+		p.index = newDeviceIndex(interval)
+		if interval > 0 {
+			go p.index.run(ctx, p.createSVC)
+		}
+	}
 }
 
 // NewProvider returns an empty provider object
-func NewProvider(spi spi.SessionProviderInterface) driver.Driver {
-	return &Provider{
-		SPI: spi,
+func NewProvider(spi spi.SessionProviderInterface, opts ...Option) driver.Driver {
+	p := &Provider{
+		SPI:              spi,
+		devices:          newDeviceCache(0),
+		index:            newDeviceIndex(0),
+		reservations:     newReservationInventory(0),
+		claimSettleDelay: DefaultClaimSettleDelay,
+		claimed:          make(map[string]bool),
+		actions:          make(map[string]string),
+		topologies:       make(map[string]string),
+		catalog:          catalog.Embedded(),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
@@ -5,13 +5,20 @@
 package provider_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
 	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	apiv1alpha2 "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/v1alpha2"
 	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -58,6 +65,45 @@ func newMachine(
 	return machine
 }
 
+// newProviderSecret returns a MachineClass secret with an API token and userdata
+func newProviderSecret() *corev1.Secret {
+	return &corev1.Secret{
+		Data: map[string][]byte{
+			"apiToken": []byte("dummy-token"),
+			"userData": []byte("dummy-user-data"),
+		},
+	}
+}
+
+// newProviderSecretWith returns the MachineClass secret with an additional key
+func newProviderSecretWith(key, value string) *corev1.Secret {
+	secret := newProviderSecret()
+	secret.Data[key] = []byte(value)
+	return secret
+}
+
+// newProviderSpec returns a valid provider spec encoded as v1alpha2, changed by modify if it is not nil
+func newProviderSpec(modify func(spec *api.EquinixMetalProviderSpec)) []byte {
+	spec := api.EquinixMetalProviderSpec{
+		Metro:        "ny",
+		MachineType:  "c3.small.x86",
+		BillingCycle: "hourly",
+		OS:           "alpine_3",
+		ProjectID:    "abcdefg",
+		Tags: []string{
+			"kubernetes.io/cluster/shoot-test: 1",
+			"kubernetes.io/role/test: 1",
+		},
+	}
+	if modify != nil {
+		modify(&spec)
+	}
+	versioned := &apiv1alpha2.EquinixMetalProviderSpec{}
+	versioned.ConvertFrom(&spec)
+	raw, _ := json.Marshal(versioned)
+	return raw
+}
+
 func newMachineClass(providerSpec []byte) *v1alpha1.MachineClass {
 	return &v1alpha1.MachineClass{
 		ProviderSpec: runtime.RawExtension{
@@ -67,7 +113,44 @@ func newMachineClass(providerSpec []byte) *v1alpha1.MachineClass {
 	}
 }
 
+// createMachine creates a machine for the provider spec and secret with a new mock plugin
+func createMachine(spec []byte, secret *corev1.Secret) (*mock.PluginSPIImpl, error) {
+	plugin := &mock.PluginSPIImpl{}
+	_, err := createMachineWith(plugin, spec, secret)
+	return plugin, err
+}
+
+// createMachineWith creates a machine for the provider spec and secret with the mock plugin
+func createMachineWith(plugin *mock.PluginSPIImpl, spec []byte, secret *corev1.Secret) (*driver.CreateMachineResponse, error) {
+	return provider.NewProvider(plugin).CreateMachine(context.Background(), &driver.CreateMachineRequest{
+		Machine:      newMachine(1),
+		MachineClass: newMachineClass(spec),
+		Secret:       secret,
+	})
+}
+
 func setProvider(machine *v1alpha1.MachineClass, provider string) *v1alpha1.MachineClass {
 	machine.Provider = provider
 	return machine
 }
+
+func setName(machineClass *v1alpha1.MachineClass, name string) *v1alpha1.MachineClass {
+	machineClass.Name = name
+	return machineClass
+}
+
+func setAnnotation(machineClass *v1alpha1.MachineClass, key, value string) *v1alpha1.MachineClass {
+	if machineClass.Annotations == nil {
+		machineClass.Annotations = make(map[string]string)
+	}
+	machineClass.Annotations[key] = value
+	return machineClass
+}
+
+func setLabel(machine *v1alpha1.Machine, key, value string) *v1alpha1.Machine {
+	if machine.Labels == nil {
+		machine.Labels = make(map[string]string)
+	}
+	machine.Labels[key] = value
+	return machine
+}
@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"k8s.io/klog/v2"
+)
+
+// The ProviderIDs of all formats are decoded, new ProviderIDs are encoded in the format of the provider spec and
+// existing ProviderIDs keep their format, which is recorded in the api.TagKeyProviderIDFormat tag of the device:
+//
+//	format   ProviderID                                       written by
+//	metro    equinixmetal://<metro>/<device id>               this provider (default)
+//	project  equinixmetal://<project id>/<metro>/<device id>  this provider (default with projects)
+//	ccm      equinixmetal://<device id>                       Equinix Metal cloud-controller-manager
+//	packet   packet://<device id>                             in-tree Packet driver and Packet cloud-controller-manager
+const (
+	schemeEquinixMetal = "equinixmetal://"
+	schemePacket       = "packet://"
+)
+
+// providerID is a decoded ProviderID, the project and metro are only set for formats containing them
+type providerID struct {
+	format    string
+	projectID string
+	metro     string
+	deviceID  string
+}
+
+// parseProviderID decodes a ProviderID of any of the supported formats
+func parseProviderID(id string) (providerID, error) {
+	if deviceID, ok := strings.CutPrefix(id, schemePacket); ok && deviceID != "" && !strings.Contains(deviceID, "/") {
+		return providerID{format: api.ProviderIDFormatPacket, deviceID: deviceID}, nil
+	}
+	path, ok := strings.CutPrefix(id, schemeEquinixMetal)
+	if !ok {
+		return providerID{}, fmt.Errorf("unsupported ProviderID %q", id)
+	}
+	segments := strings.Split(path, "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return providerID{}, fmt.Errorf("unsupported ProviderID %q", id)
+		}
+	}
+	switch len(segments) {
+	case 1:
+		return providerID{format: api.ProviderIDFormatCCM, deviceID: segments[0]}, nil
+	case 2:
+		return providerID{format: api.ProviderIDFormatMetro, metro: segments[0], deviceID: segments[1]}, nil
+	case 3:
+		return providerID{format: api.ProviderIDFormatProject, projectID: segments[0], metro: segments[1], deviceID: segments[2]}, nil
+	}
+	return providerID{}, fmt.Errorf("unsupported ProviderID %q", id)
+}
+
+// encodeMachineID returns the ProviderID of the device in the given format, projectID is the project the device
+// was created in
+func encodeMachineID(device *metalv1.Device, format, projectID string) string {
+	switch format {
+	case api.ProviderIDFormatPacket:
+		return schemePacket + device.GetId()
+	case api.ProviderIDFormatCCM:
+		return schemeEquinixMetal + device.GetId()
+	case api.ProviderIDFormatProject:
+		return fmt.Sprintf("%s%s/%s/%s", schemeEquinixMetal, projectID, device.Metro.GetCode(), device.GetId())
+	}
+	return fmt.Sprintf("%s%s/%s", schemeEquinixMetal, device.Metro.GetCode(), device.GetId())
+}
+
+// reencodeMachineID returns the ProviderID of the device in the format of an existing ProviderID, so that it is
+// kept stable, or in the default format if the existing ProviderID cannot be decoded
+func reencodeMachineID(device *metalv1.Device, id string) string {
+	existing, err := parseProviderID(id)
+	if err != nil {
+		return encodeMachineID(device, api.ProviderIDFormatMetro, "")
+	}
+	return encodeMachineID(device, existing.format, existing.projectID)
+}
+
+// providerIDFormatTag returns the ProviderID format recorded in the device tags, or an empty string
+func providerIDFormatTag(tags []string) string {
+	for _, tag := range api.ParseTags(tags) {
+		if tag.Key == api.TagKeyProviderIDFormat {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// withProviderIDFormatTag returns the device tags with the ProviderID format tag set to format
+func withProviderIDFormatTag(tags []string, format string) []string {
+	result := make([]string, 0, len(tags)+1)
+	for _, tag := range tags {
+		if api.ParseTag(tag).Key != api.TagKeyProviderIDFormat {
+			result = append(result, tag)
+		}
+	}
+	return append(result, api.Tag{Key: api.TagKeyProviderIDFormat, Value: format}.String())
+}
+
+// stampProviderIDFormat records the format of the existing ProviderID of a machine in the tags of its device, so
+// that ListMachines keeps reporting the device under that ProviderID when the format of the MachineClass changes.
+// The device is read again before its tags are replaced, failures are logged only and retried with the next status
+// check. It returns the updated device.
+func (p *Provider) stampProviderIDFormat(ctx context.Context, svc spi.MetalDeviceService, id string, device *metalv1.Device) *metalv1.Device {
+	existing, err := parseProviderID(id)
+	if err != nil || providerIDFormatTag(device.Tags) == existing.format {
+		return device
+	}
+	logger := klog.FromContext(ctx).WithValues(logging.KeyProviderID, id)
+	current, _, err := svc.FindDeviceByID(ctx, device.GetId())
+	if err != nil {
+		logger.Error(err, "Could not get device to record its ProviderID format")
+		return device
+	}
+	if providerIDFormatTag(current.Tags) == existing.format {
+		return current
+	}
+	updated, _, err := svc.UpdateDevice(ctx, device.GetId(), metalv1.DeviceUpdateInput{
+		Tags: withProviderIDFormatTag(current.Tags, existing.format),
+	})
+	if err != nil {
+		logger.Error(err, "Could not record ProviderID format of device")
+		return current
+	}
+	return updated
+}
+
+// decodeMachineID returns the device ID of a ProviderID, which is the last path segment of unsupported ProviderIDs
+func decodeMachineID(id string) string {
+	if decoded, err := parseProviderID(id); err == nil {
+		return decoded.deviceID
+	}
+	splitProviderID := strings.Split(id, "/")
+	return splitProviderID[len(splitProviderID)-1]
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// the compatibility matrix of the ProviderID formats documented in providerid.go
+var _ = Describe("ProviderID", func() {
+	providerSecret := newProviderSecret()
+	newPlugin := func() *mock.PluginSPIImpl {
+		var (
+			id       = "d1"
+			metro    = "ny"
+			project  = "abcdefg"
+			hostname = "machine-1"
+		)
+		return &mock.PluginSPIImpl{
+			Devices: []metalv1.Device{{
+				Id:       &id,
+				Hostname: &hostname,
+				Metro:    &metalv1.DeviceMetro{Code: &metro},
+				Project:  &metalv1.Project{Id: &project},
+				Tags:     []string{"kubernetes.io/cluster/shoot-test: 1", "kubernetes.io/role/test: 1"},
+			}},
+		}
+	}
+
+	table.DescribeTable("should encode new ProviderIDs in the configured format",
+		func(format, expected string) {
+			resp, err := createMachineWith(&mock.PluginSPIImpl{}, newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+				spec.ProviderIDFormat = format
+			}), providerSecret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.ProviderID).To(Equal(expected))
+		},
+		table.Entry("default", "", "equinixmetal://ny/000001"),
+		table.Entry("metro", api.ProviderIDFormatMetro, "equinixmetal://ny/000001"),
+		table.Entry("project", api.ProviderIDFormatProject, "equinixmetal://abcdefg/ny/000001"),
+		table.Entry("ccm", api.ProviderIDFormatCCM, "equinixmetal://000001"),
+		table.Entry("packet", api.ProviderIDFormatPacket, "packet://000001"),
+	)
+
+	table.DescribeTable("should list machines in the configured format",
+		func(format, expected string) {
+			resp, err := provider.NewProvider(newPlugin()).ListMachines(context.Background(), &driver.ListMachinesRequest{
+				MachineClass: newMachineClass(newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.ProviderIDFormat = format })),
+				Secret:       providerSecret,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.MachineList).To(Equal(map[string]string{expected: "machine-1"}))
+		},
+		table.Entry("metro", api.ProviderIDFormatMetro, "equinixmetal://ny/d1"),
+		table.Entry("project", api.ProviderIDFormatProject, "equinixmetal://abcdefg/ny/d1"),
+		table.Entry("ccm", api.ProviderIDFormatCCM, "equinixmetal://d1"),
+		table.Entry("packet", api.ProviderIDFormatPacket, "packet://d1"),
+	)
+
+	// existing ProviderIDs keep their format regardless of the configured one
+	table.DescribeTable("should decode existing ProviderIDs of every format",
+		func(providerID string) {
+			plugin := newPlugin()
+			machine := newMachine(1)
+			machine.Spec.ProviderID = providerID
+			p := provider.NewProvider(plugin)
+			resp, err := p.GetMachineStatus(context.Background(), &driver.GetMachineStatusRequest{
+				Machine:      machine,
+				MachineClass: newMachineClass(newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.ProviderIDFormat = api.ProviderIDFormatMetro })),
+				Secret:       providerSecret,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.ProviderID).To(Equal(providerID))
+
+			_, err = p.DeleteMachine(context.Background(), &driver.DeleteMachineRequest{
+				Machine:      machine,
+				MachineClass: newMachineClass(newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.ProviderIDFormat = api.ProviderIDFormatMetro })),
+				Secret:       providerSecret,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plugin.Devices).To(BeEmpty())
+		},
+		table.Entry("metro", "equinixmetal://ny/d1"),
+		table.Entry("project", "equinixmetal://abcdefg/ny/d1"),
+		table.Entry("ccm", "equinixmetal://d1"),
+		table.Entry("packet", "packet://d1"),
+	)
+})
+
+var _ = Describe("ProviderID format changes", func() {
+	providerSecret := newProviderSecret()
+
+	// like the orphan collection of the safety controller, which deletes the devices of unknown ProviderIDs
+	It("should not report existing machines as orphans", func() {
+		var (
+			id       = "legacy"
+			metro    = "ny"
+			project  = "abcdefg"
+			hostname = "machine-0"
+			plugin   = &mock.PluginSPIImpl{
+				// created before the ProviderID format was recorded
+				Devices: []metalv1.Device{{
+					Id:       &id,
+					Hostname: &hostname,
+					Metro:    &metalv1.DeviceMetro{Code: &metro},
+					Project:  &metalv1.Project{Id: &project},
+					Tags:     []string{"kubernetes.io/cluster/shoot-test: 1", "kubernetes.io/role/test: 1"},
+				}},
+			}
+			p        = provider.NewProvider(plugin)
+			ctx      = context.Background()
+			machines = map[string]string{"packet://legacy": "machine-0"}
+		)
+		for i := 1; i <= 2; i++ {
+			resp, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      newMachine(i),
+				MachineClass: newMachineClass(newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.ProviderIDFormat = api.ProviderIDFormatMetro })),
+				Secret:       providerSecret,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			machines[resp.ProviderID] = resp.NodeName
+		}
+		for providerID, name := range machines {
+			machine := newMachine(0)
+			machine.Name = name
+			machine.Spec.ProviderID = providerID
+			_, err := p.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+				Machine:      machine,
+				MachineClass: newMachineClass(newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.ProviderIDFormat = api.ProviderIDFormatMetro })),
+				Secret:       providerSecret,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		for _, format := range []string{api.ProviderIDFormatCCM, api.ProviderIDFormatProject, api.ProviderIDFormatPacket} {
+			resp, err := p.ListMachines(ctx, &driver.ListMachinesRequest{
+				MachineClass: newMachineClass(newProviderSpec(func(spec *api.EquinixMetalProviderSpec) { spec.ProviderIDFormat = format })),
+				Secret:       providerSecret,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			var orphans []string
+			for providerID := range resp.MachineList {
+				if _, ok := machines[providerID]; !ok {
+					orphans = append(orphans, providerID)
+				}
+			}
+			Expect(orphans).To(BeEmpty(), "format %s", format)
+			Expect(resp.MachineList).To(Equal(machines), "format %s", format)
+		}
+	})
+})
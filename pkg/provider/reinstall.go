@@ -0,0 +1,334 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// parkedDescription is the description of the devices in the free pool
+	parkedDescription = "Parked for reuse by the Gardener machine-controller-manager"
+
+	// freePoolParking is the free pool tag value of a device whose reinstallation has not been requested yet
+	freePoolParking = "parking"
+	// freePoolParked is the free pool tag value of a device that has been reinstalled for reuse
+	freePoolParked = "true"
+	// freePoolClaimPrefix is the prefix of the free pool tag value "claimed/<token>/<unix time>" of a device claimed
+	// for a new machine
+	freePoolClaimPrefix = "claimed/"
+
+	// claimTimeout is the time after which claims of controllers that did not complete them expire
+	claimTimeout = 10 * time.Minute
+	// DefaultClaimSettleDelay is the default time a claim has to persist before the parked device is reused, it
+	// exceeds the latency of the device updates of the API, so that concurrent claims are seen
+	DefaultClaimSettleDelay = 2 * time.Second
+)
+
+// freePoolState returns the value of the free pool tag of the device, or an empty string if it is not in the free pool
+func freePoolState(device *metalv1.Device) string {
+	for _, tag := range api.ParseTags(device.Tags) {
+		if tag.Key == api.TagKeyFreePool {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// isParked returns whether the device is in the free pool of its project, including devices still being parked
+func isParked(device *metalv1.Device) bool {
+	return freePoolState(device) != ""
+}
+
+// parkDevice re-images the device with a hardware reservation and parks it in the free pool of its project
+// instead of deleting it. The tags and userdata of the machine are removed first, so that the device cannot
+// rejoin the cluster, and the device is only marked as parked once its reinstallation has been requested, so that a
+// failed request is retried. It returns false without error if the device has no hardware reservation or does not
+// exist, so that it is deleted instead. Locked devices are unlocked as for their deletion.
+func (p *Provider) parkDevice(ctx context.Context, svc spi.MetalDeviceService, machine *v1alpha1.Machine, deviceID string) (bool, error) {
+	logger := klog.FromContext(ctx)
+	device, resp, err := svc.FindDeviceByID(ctx, deviceID)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if device.HardwareReservation == nil {
+		logger.V(3).Info("Device has no hardware reservation, deleting it instead of parking it")
+		return false, nil
+	}
+	state := freePoolState(device)
+	if state == freePoolParked {
+		return true, nil
+	}
+	if device.GetLocked() {
+		if err := unlockForDeletion(ctx, svc, machine, deviceID); err != nil {
+			return false, err
+		}
+	}
+
+	if state != freePoolParking {
+		if err := park(ctx, svc, device, freePoolParking); err != nil {
+			return false, err
+		}
+	}
+	// a reinstallation accepted before a failed attempt to mark the device as parked is not requested again
+	if state != freePoolParking || device.GetState() != metalv1.DEVICESTATE_REINSTALLING {
+		if _, err := svc.PerformAction(ctx, deviceID, metalv1.DeviceActionInput{
+			Type:            metalv1.DEVICEACTIONINPUTTYPE_REINSTALL,
+			DeprovisionFast: metalv1.PtrBool(false),
+			PreserveData:    metalv1.PtrBool(false),
+		}); err != nil {
+			return false, err
+		}
+	}
+	if _, _, err := svc.UpdateDevice(ctx, deviceID, metalv1.DeviceUpdateInput{Tags: freePoolTags(device.Tags, freePoolParked)}); err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	delete(p.claimed, deviceID)
+	p.mu.Unlock()
+	logger.V(2).Info("Device has been parked for reuse", logging.KeyReservationID, device.HardwareReservation.GetId())
+	return true, nil
+}
+
+// park replaces the tags, userdata and description of the device with those of the free pool in the given state
+func park(ctx context.Context, svc spi.MetalDeviceService, device *metalv1.Device, state string) error {
+	_, _, err := svc.UpdateDevice(ctx, device.GetId(), metalv1.DeviceUpdateInput{
+		Description: metalv1.PtrString(parkedDescription),
+		Userdata:    metalv1.PtrString(""),
+		Tags:        freePoolTags(device.Tags, state),
+	})
+	return err
+}
+
+// freePoolTags returns the tags of a device in the free pool, which are the free pool tag in the given state and the
+// SSH keys tag of the device, so that it is not listed for any cluster
+func freePoolTags(tags []string, state string) []string {
+	result := []api.Tag{{Key: api.TagKeyFreePool, Value: state}}
+	for _, tag := range api.ParseTags(tags) {
+		if tag.Key == api.TagKeySSHKeys {
+			result = append(result, tag)
+		}
+	}
+	return api.FormatTags(result)
+}
+
+// claimable returns whether a parked device can be claimed, which it can if it is not claimed or its claim expired
+func claimable(device *metalv1.Device, now time.Time) bool {
+	state := freePoolState(device)
+	if state == freePoolParked {
+		return true
+	}
+	claim, ok := strings.CutPrefix(state, freePoolClaimPrefix)
+	if !ok {
+		return false
+	}
+	_, claimed, _ := strings.Cut(claim, "/")
+	unix, err := strconv.ParseInt(claimed, 10, 64)
+	return err != nil || now.Sub(time.Unix(unix, 0)) > claimTimeout
+}
+
+// claim claims the parked device by writing a new claim into its free pool tag and returns the claim, or an empty
+// string if the device could not be claimed. Devices are shared by all controllers of the project, so the device is
+// read again afterwards and once more after the settle delay, and the claim only succeeds if the claim written last
+// is still ours. A controller that read the device before our claim is thereby given the time to overwrite it.
+func (p *Provider) claim(ctx context.Context, svc spi.MetalDeviceService, deviceID string) (string, error) {
+	device, _, err := svc.FindDeviceByID(ctx, deviceID)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if !claimable(device, now) {
+		return "", nil
+	}
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	value := fmt.Sprintf("%s%x/%d", freePoolClaimPrefix, token, now.Unix())
+	if _, _, err := svc.UpdateDevice(ctx, deviceID, metalv1.DeviceUpdateInput{Tags: freePoolTags(device.Tags, value)}); err != nil {
+		return "", err
+	}
+	if held, err := claimHeld(ctx, svc, deviceID, value); err != nil || !held {
+		return "", err
+	}
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(p.claimSettleDelay):
+	}
+	if held, err := claimHeld(ctx, svc, deviceID, value); err != nil || !held {
+		return "", err
+	}
+	return value, nil
+}
+
+// claimHeld returns whether the free pool tag of the device still carries the claim
+func claimHeld(ctx context.Context, svc spi.MetalDeviceService, deviceID, value string) (bool, error) {
+	device, _, err := svc.FindDeviceByID(ctx, deviceID)
+	if err != nil {
+		return false, err
+	}
+	return freePoolState(device) == value, nil
+}
+
+// claimParkedDevice claims an active parked device of the given project matching the metros, plans and reservations of
+// the provider spec as well as the fingerprint of its SSH keys and reinstalls it for the machine described by the
+// update and the customdata, which are stamped with the topology of the device. It returns nil without error if no
+// parked device matches.
+func (p *Provider) claimParkedDevice(
+	ctx context.Context,
+	svc spi.MetalDeviceService,
+	providerSpec *api.EquinixMetalProviderSpec,
+	projectID string,
+	sshKeys string,
+	update metalv1.DeviceUpdateInput,
+	customData map[string]interface{},
+) (*metalv1.Device, error) {
+	logger := klog.FromContext(ctx)
+	tags := update.Tags
+	// the listing must not be cached, claimed devices lose the free pool tag
+	list, _, err := svc.FindProjectDevices(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	metros, plans := providerSpec.MetrosAndPlans()
+	reservations := make(map[string]bool)
+	for _, id := range providerSpec.ReservationIDs {
+		reservations[id] = true
+	}
+	rank := func(device *metalv1.Device) int {
+		metro, plan := indexOf(metros, device.Metro.GetCode()), indexOf(plans, device.Plan.GetSlug())
+		if metro < 0 || plan < 0 {
+			return -1
+		}
+		return metro*len(plans) + plan
+	}
+	var (
+		candidates []*metalv1.Device
+		now        = time.Now()
+	)
+	for i := range list.Devices {
+		device := &list.Devices[i]
+		// the SSH keys of a device cannot be changed by its reinstallation
+		if !claimable(device, now) || sshKeysTag(device.Tags) != sshKeys || device.GetState() != metalv1.DEVICESTATE_ACTIVE || device.HardwareReservation == nil ||
+			device.Metro == nil || device.Plan == nil || rank(device) < 0 {
+			continue
+		}
+		if len(reservations) > 0 && !reservations[device.HardwareReservation.GetId()] {
+			continue
+		}
+		candidates = append(candidates, device)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return rank(candidates[i]) < rank(candidates[j]) })
+
+	release := func(deviceID string) {
+		p.mu.Lock()
+		delete(p.claimed, deviceID)
+		p.mu.Unlock()
+	}
+	var (
+		device *metalv1.Device
+		value  string
+	)
+	for _, candidate := range candidates {
+		id := candidate.GetId()
+		p.mu.Lock()
+		claimed := p.claimed[id]
+		p.claimed[id] = true
+		p.mu.Unlock()
+		if claimed {
+			continue
+		}
+		// the customdata is validated before the claim, as the device cannot be reused with customdata exceeding
+		// the maximum length
+		metro, facility := candidate.Metro.GetCode(), candidate.Facility.GetCode()
+		update.Customdata, err = stampedCustomData(customData, metro, facility)
+		if err != nil {
+			release(id)
+			return nil, err
+		}
+		update.Tags = withTopologyTags(tags, metro, facility)
+		value, err = p.claim(ctx, svc, id)
+		if err != nil {
+			release(id)
+			return nil, err
+		}
+		if value != "" {
+			device = candidate
+			break
+		}
+		release(id)
+		logger.V(3).Info("Parked device has been claimed by another controller", logging.KeyDeviceID, id)
+	}
+	if device == nil {
+		logger.V(3).Info("No parked device available for reuse", "parkedCandidates", len(candidates))
+		return nil, nil
+	}
+
+	logger = logger.WithValues(logging.KeyDeviceID, device.GetId(), logging.KeyReservationID, device.HardwareReservation.GetId())
+	// the claim is checked once more right before the device is taken over, as it is not locked by the claim
+	held, err := claimHeld(ctx, svc, device.GetId(), value)
+	if err != nil || !held {
+		release(device.GetId())
+		if err == nil {
+			logger.V(3).Info("Parked device has been claimed by another controller")
+		}
+		return nil, err
+	}
+	updated, _, err := svc.UpdateDevice(ctx, device.GetId(), update)
+	if err != nil {
+		if parkErr := park(ctx, svc, device, freePoolParked); parkErr != nil {
+			logger.Error(parkErr, "Could not return device to the free pool")
+		} else {
+			release(device.GetId())
+		}
+		return nil, err
+	}
+	if _, err := svc.PerformAction(ctx, device.GetId(), metalv1.DeviceActionInput{
+		Type:            metalv1.DEVICEACTIONINPUTTYPE_REINSTALL,
+		DeprovisionFast: metalv1.PtrBool(true),
+		OperatingSystem: metalv1.PtrString(providerSpec.OS),
+		IpxeScriptUrl:   providerSpec.IPXEScriptURL,
+	}); err != nil {
+		// return the device to the free pool, so that it can be claimed again
+		if parkErr := park(ctx, svc, device, freePoolParked); parkErr != nil {
+			logger.Error(parkErr, "Could not return device to the free pool")
+		} else {
+			release(device.GetId())
+		}
+		return nil, err
+	}
+	logger.V(2).Info("Claimed parked device for reuse")
+	return updated, nil
+}
+
+// indexOf returns the index of s in list, or -1 if it is missing
+func indexOf(list []string, s string) int {
+	for i := range list {
+		if list[i] == s {
+			return i
+		}
+	}
+	return -1
+}
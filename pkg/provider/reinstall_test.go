@@ -0,0 +1,372 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/validation"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("ReplacementStrategy", func() {
+	var (
+		ctx    = context.Background()
+		plugin *mock.PluginSPIImpl
+		p      driver.Driver
+	)
+	providerSecret := newProviderSecret()
+	freePoolTag := api.Tag{Key: api.TagKeyFreePool, Value: "true"}.String()
+	newReservation := func(id string) metalv1.HardwareReservation {
+		return metalv1.HardwareReservation{
+			Id:       &id,
+			Plan:     &metalv1.Plan{Slug: metalv1.PtrString("c3.small.x86")},
+			Facility: &metalv1.Facility{Metro: &metalv1.DeviceMetro{Code: metalv1.PtrString("ny")}},
+		}
+	}
+	newSpec := func(modify func(spec *api.EquinixMetalProviderSpec)) []byte {
+		return newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProjectID = "reserved-project"
+			modify(spec)
+		})
+	}
+	newMachineClassSpec := func(strategy string, reservationIDs ...string) []byte {
+		return newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ReservationIDs = reservationIDs
+			spec.ReplacementStrategy = strategy
+		})
+	}
+	createMachine := func(machine *v1alpha1.Machine, spec []byte) *driver.CreateMachineResponse {
+		resp, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(spec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+	deleteMachine := func(machine *v1alpha1.Machine, spec []byte, providerID string) {
+		machine.Spec.ProviderID = providerID
+		_, err := p.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(spec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+	// activate finishes the reinstallation of all devices
+	activate := func() {
+		for i := range plugin.Devices {
+			plugin.Devices[i].State = metalv1.DEVICESTATE_ACTIVE.Ptr()
+		}
+	}
+
+	BeforeEach(func() {
+		plugin = &mock.PluginSPIImpl{
+			HardwareReservations: []metalv1.HardwareReservation{newReservation("res-1"), newReservation("res-2")},
+		}
+		p = provider.NewProvider(plugin, provider.WithClaimSettleDelay(0))
+	})
+
+	It("should park reserved devices and reinstall them for new machines", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1")
+		machine := newMachine(1)
+		created := createMachine(machine, spec)
+		Expect(plugin.Devices).To(HaveLen(1))
+		id := plugin.Devices[0].GetId()
+
+		deleteMachine(machine, spec, created.ProviderID)
+		Expect(plugin.Devices).To(HaveLen(1))
+		Expect(plugin.Devices[0].Tags).To(ConsistOf(freePoolTag, HavePrefix(api.TagKeySSHKeys+": ")))
+		Expect(plugin.Devices[0].GetUserdata()).To(BeEmpty())
+		Expect(plugin.Actions).To(Equal([]string{id + "/reinstall"}))
+
+		list, err := p.ListMachines(ctx, &driver.ListMachinesRequest{
+			MachineClass: newMachineClass(spec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.MachineList).To(BeEmpty())
+		_, err = p.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(spec),
+			Secret:       providerSecret,
+		})
+		Expect(err).To(HaveOccurred())
+
+		// a parked device is only claimed once its reinstallation has finished
+		activate()
+		replacement := newMachine(2)
+		resp := createMachine(replacement, spec)
+		Expect(resp.ProviderID).To(Equal(created.ProviderID))
+		Expect(resp.NodeName).To(Equal(replacement.Name))
+		Expect(plugin.Devices).To(HaveLen(1))
+		Expect(plugin.Devices[0].GetHostname()).To(Equal(replacement.Name))
+		Expect(plugin.Devices[0].GetUserdata()).To(Equal("dummy-user-data"))
+		Expect(plugin.Devices[0].Tags).NotTo(ContainElement(freePoolTag))
+		Expect(plugin.Actions).To(Equal([]string{id + "/reinstall", id + "/reinstall"}))
+	})
+
+	It("should only park devices once their reinstallation has been requested", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1")
+		machine := newMachine(1)
+		created := createMachine(machine, spec)
+		id := plugin.Devices[0].GetId()
+
+		plugin.FailActions = map[metalv1.DeviceActionInputType]bool{metalv1.DEVICEACTIONINPUTTYPE_REINSTALL: true}
+		machine.Spec.ProviderID = created.ProviderID
+		_, err := p.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(spec),
+			Secret:       providerSecret,
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(plugin.Devices[0].Tags).NotTo(ContainElement(freePoolTag))
+		Expect(plugin.Devices[0].GetUserdata()).To(BeEmpty())
+		Expect(plugin.Actions).To(BeEmpty())
+
+		// the retried deletion requests the reinstallation again instead of taking the device as parked
+		plugin.FailActions = nil
+		deleteMachine(machine, spec, created.ProviderID)
+		Expect(plugin.Devices[0].Tags).To(ConsistOf(freePoolTag, HavePrefix(api.TagKeySSHKeys+": ")))
+		Expect(plugin.Actions).To(Equal([]string{id + "/reinstall"}))
+	})
+
+	It("should not claim parked devices that are still reinstalling or have another reservation", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2")
+		machine := newMachine(1)
+		created := createMachine(machine, spec)
+		deleteMachine(machine, spec, created.ProviderID)
+
+		resp := createMachine(newMachine(2), spec)
+		Expect(resp.ProviderID).NotTo(Equal(created.ProviderID))
+		Expect(plugin.Devices).To(HaveLen(2))
+
+		activate()
+		resp = createMachine(newMachine(3), newMachineClassSpec(api.ReplacementStrategyReinstall, "res-3"))
+		Expect(resp.ProviderID).NotTo(Equal(created.ProviderID))
+	})
+
+	// parks a device created for the spec and returns its ProviderID
+	parkDevice := func(spec []byte) string {
+		machine := newMachine(1)
+		created := createMachine(machine, spec)
+		deleteMachine(machine, spec, created.ProviderID)
+		activate()
+		return created.ProviderID
+	}
+	// claimTag returns the free pool tag of a device claimed by another controller at the given time
+	claimTag := func(at time.Time) string {
+		return api.Tag{Key: api.TagKeyFreePool, Value: fmt.Sprintf("claimed/0123456789abcdef/%d", at.Unix())}.String()
+	}
+	setFreePoolTag := func(tag string) {
+		for i, t := range plugin.Devices[0].Tags {
+			if strings.HasPrefix(t, api.TagKeyFreePool) {
+				plugin.Devices[0].Tags[i] = tag
+			}
+		}
+	}
+
+	It("should not claim parked devices claimed by another controller", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2")
+		parked := parkDevice(spec)
+		setFreePoolTag(claimTag(time.Now()))
+
+		resp := createMachine(newMachine(2), spec)
+		Expect(resp.ProviderID).NotTo(Equal(parked))
+		Expect(plugin.Devices[0].Tags).To(ContainElement(claimTag(time.Now())))
+	})
+
+	It("should claim parked devices whose claim expired", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2")
+		parked := parkDevice(spec)
+		setFreePoolTag(claimTag(time.Now().Add(-time.Hour)))
+
+		resp := createMachine(newMachine(2), spec)
+		Expect(resp.ProviderID).To(Equal(parked))
+	})
+
+	It("should not reinstall parked devices when another controller claimed them concurrently", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2")
+		parked := parkDevice(spec)
+		other := claimTag(time.Now())
+		plugin.AfterUpdateDevice = func(device *metalv1.Device) {
+			for i, tag := range device.Tags {
+				if strings.HasPrefix(tag, api.TagKeyFreePool+": claimed/") {
+					device.Tags[i] = other
+				}
+			}
+		}
+
+		resp := createMachine(newMachine(2), spec)
+		Expect(resp.ProviderID).NotTo(Equal(parked))
+		Expect(plugin.Devices[0].Tags).To(ContainElement(other))
+		Expect(plugin.Actions).To(HaveLen(1))
+	})
+
+	It("should reinstall a parked device for only one of two controllers claiming it concurrently", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2")
+		parked := parkDevice(spec)
+		id := plugin.Devices[0].GetId()
+		// B reads the device as parked, A reads it as parked, A writes and reads back its claim, B writes and reads
+		// back its claim
+		steps := &interleaving{steps: []string{
+			"B:FindDeviceByID", "A:FindDeviceByID", "A:UpdateDevice", "A:FindDeviceByID", "B:UpdateDevice", "B:FindDeviceByID",
+		}}
+		steps.cond = sync.NewCond(&steps.mu)
+
+		providerIDs := make(chan string, 2)
+		for i, name := range []string{"A", "B"} {
+			claimer := provider.NewProvider(&interleavedSessionProvider{SessionProviderInterface: plugin, name: name, steps: steps},
+				provider.WithClaimSettleDelay(0))
+			machine := newMachine(i + 2)
+			go func() {
+				defer GinkgoRecover()
+				resp, err := claimer.CreateMachine(ctx, &driver.CreateMachineRequest{
+					Machine:      machine,
+					MachineClass: newMachineClass(spec),
+					Secret:       providerSecret,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				providerIDs <- resp.ProviderID
+			}()
+		}
+		var first, second string
+		Eventually(providerIDs, 10*time.Second).Should(Receive(&first))
+		Eventually(providerIDs, 10*time.Second).Should(Receive(&second))
+		Expect([]string{first, second}).To(ConsistOf(parked, Not(Equal(parked))))
+		Expect(plugin.Actions).To(Equal([]string{id + "/reinstall", id + "/reinstall"}))
+	})
+
+	It("should stamp the topology of reused devices", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2")
+		parked := parkDevice(spec)
+		plugin.Devices[0].Facility = &metalv1.Facility{Code: metalv1.PtrString("ny5")}
+
+		resp := createMachine(newMachine(2), spec)
+		Expect(resp.ProviderID).To(Equal(parked))
+		Expect(plugin.Devices[0].Tags).To(ContainElements(api.TagKeyMetro+": ny", api.TagKeyFacility+": ny5"))
+		Expect(plugin.Devices[0].Customdata).To(HaveKeyWithValue("topology", map[string]interface{}{"metro": "ny", "facility": "ny5"}))
+	})
+
+	It("should not claim parked devices for customdata exceeding the maximum length with the topology", func() {
+		parkDevice(newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2"))
+
+		_, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine: newMachine(2),
+			MachineClass: newMachineClass(newSpec(func(spec *api.EquinixMetalProviderSpec) {
+				spec.ReservationIDs = []string{"res-1", "res-2"}
+				spec.ReplacementStrategy = api.ReplacementStrategyReinstall
+				spec.CustomData = []byte(`{"padding":"` + strings.Repeat("x", validation.CustomDataMaxLength-20) + `"}`)
+			})),
+			Secret: providerSecret,
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(plugin.Devices[0].Tags).To(ContainElement(freePoolTag))
+	})
+
+	It("should only reuse parked devices with the same SSH keys", func() {
+		parked := parkDevice(newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2"))
+
+		noKeys := newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ReservationIDs = []string{"res-1", "res-2"}
+			spec.ReplacementStrategy = api.ReplacementStrategyReinstall
+			spec.NoSSHKeys = true
+		})
+		resp := createMachine(newMachine(2), noKeys)
+		Expect(resp.ProviderID).NotTo(Equal(parked))
+
+		resp = createMachine(newMachine(3), newMachineClassSpec(api.ReplacementStrategyReinstall, "res-1", "res-2"))
+		Expect(resp.ProviderID).To(Equal(parked))
+	})
+
+	It("should delete reserved devices without the reinstall strategy", func() {
+		spec := newMachineClassSpec("", "res-1")
+		machine := newMachine(1)
+		created := createMachine(machine, spec)
+		deleteMachine(machine, spec, created.ProviderID)
+		Expect(plugin.Devices).To(BeEmpty())
+		Expect(plugin.Actions).To(BeEmpty())
+	})
+
+	It("should delete on-demand devices with the reinstall strategy", func() {
+		spec := newMachineClassSpec(api.ReplacementStrategyReinstall)
+		machine := newMachine(1)
+		created := createMachine(machine, spec)
+		deleteMachine(machine, spec, created.ProviderID)
+		Expect(plugin.Devices).To(BeEmpty())
+		Expect(plugin.Actions).To(BeEmpty())
+	})
+})
+
+// interleaving lets the device reads and updates of concurrent controllers pass in the order of its steps, the calls
+// after the last step pass in any order
+type interleaving struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	steps []string
+}
+
+// await blocks until the step is the next one
+func (i *interleaving) await(step string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for len(i.steps) > 0 && i.steps[0] != step {
+		i.cond.Wait()
+	}
+	if len(i.steps) > 0 {
+		i.steps = i.steps[1:]
+	}
+	i.cond.Broadcast()
+}
+
+// interleavedSessionProvider creates device services whose reads and updates of devices are steps of an interleaving
+type interleavedSessionProvider struct {
+	spi.SessionProviderInterface
+	name  string
+	steps *interleaving
+}
+
+func (s *interleavedSessionProvider) NewSession(secret *corev1.Secret) (spi.MetalDeviceService, error) {
+	svc, err := s.SessionProviderInterface.NewSession(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &interleavedDeviceService{MetalDeviceService: svc, name: s.name, steps: s.steps}, nil
+}
+
+type interleavedDeviceService struct {
+	spi.MetalDeviceService
+	name  string
+	steps *interleaving
+}
+
+func (s *interleavedDeviceService) FindDeviceByID(ctx context.Context, deviceID string) (*metalv1.Device, *http.Response, error) {
+	s.steps.await(s.name + ":FindDeviceByID")
+	return s.MetalDeviceService.FindDeviceByID(ctx, deviceID)
+}
+
+func (s *interleavedDeviceService) UpdateDevice(
+	ctx context.Context,
+	deviceID string,
+	updateDeviceInput metalv1.DeviceUpdateInput,
+) (*metalv1.Device, *http.Response, error) {
+	s.steps.await(s.name + ":UpdateDevice")
+	return s.MetalDeviceService.UpdateDevice(ctx, deviceID, updateDeviceInput)
+}
@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// reservationInventoryIdleIntervals is the number of intervals after which the reservations of a MachineClass
+// no machines were listed for are no longer inventoried
+const reservationInventoryIdleIntervals = 10
+
+// reservationInventory periodically exports the number of total, used and idle hardware reservations of the
+// MachineClasses the provider lists machines for by plan and metro
+type reservationInventory struct {
+	// interval is the interval between the inventories of a project, 0 disables the inventory
+	interval time.Duration
+
+	mu       sync.Mutex
+	projects map[deviceCacheKey]*inventoriedProject
+}
+
+type inventoriedProject struct {
+	// secret is the secret of the latest request for the project, it is used for listing its reservations
+	secret *corev1.Secret
+	// machineClasses are the MachineClasses using reservations of the project by name
+	machineClasses map[string]*inventoriedMachineClass
+}
+
+type inventoriedMachineClass struct {
+	reservationIDs []string
+	// requested is the time of the latest request for the MachineClass
+	requested time.Time
+}
+
+func newReservationInventory(interval time.Duration) *reservationInventory {
+	return &reservationInventory{
+		interval: interval,
+		projects: make(map[deviceCacheKey]*inventoriedProject),
+	}
+}
+
+// watch adds the reservations of the MachineClass to the inventory of the project or marks them as requested again
+func (i *reservationInventory) watch(key deviceCacheKey, secret *corev1.Secret, machineClass string, reservationIDs []string) {
+	if i.interval == 0 || len(reservationIDs) == 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	project, ok := i.projects[key]
+	if !ok {
+		project = &inventoriedProject{machineClasses: make(map[string]*inventoriedMachineClass)}
+		i.projects[key] = project
+	}
+	project.secret = secret
+	project.machineClasses[machineClass] = &inventoriedMachineClass{reservationIDs: reservationIDs, requested: time.Now()}
+}
+
+// run inventories the reservations of the watched projects once per interval until the context is done
+func (i *reservationInventory) run(ctx context.Context, createSVC func(*corev1.Secret) (spi.MetalDeviceService, error)) {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			i.refresh(ctx, createSVC)
+		}
+	}
+}
+
+// refresh inventories the reservations of all watched projects and drops the MachineClasses that were not requested
+// for a while. Failures are logged only, the metrics then keep their previous values.
+func (i *reservationInventory) refresh(ctx context.Context, createSVC func(*corev1.Secret) (spi.MetalDeviceService, error)) {
+	type snapshot struct {
+		secret         *corev1.Secret
+		machineClasses map[string][]string
+	}
+	i.mu.Lock()
+	projects := make(map[deviceCacheKey]snapshot, len(i.projects))
+	for key, project := range i.projects {
+		machineClasses := make(map[string][]string, len(project.machineClasses))
+		for name, machineClass := range project.machineClasses {
+			if time.Since(machineClass.requested) >= reservationInventoryIdleIntervals*i.interval {
+				delete(project.machineClasses, name)
+				deleteReservationMetrics(key.projectID, name)
+				continue
+			}
+			machineClasses[name] = machineClass.reservationIDs
+		}
+		if len(machineClasses) == 0 {
+			delete(i.projects, key)
+			continue
+		}
+		projects[key] = snapshot{secret: project.secret, machineClasses: machineClasses}
+	}
+	i.mu.Unlock()
+
+	for key, project := range projects {
+		logger := klog.FromContext(ctx).WithValues(logging.KeyProjectID, key.projectID)
+		svc, err := createSVC(project.secret)
+		if err != nil {
+			logger.Error(err, "Could not inventory hardware reservations")
+			continue
+		}
+		list, _, err := svc.FindProjectHardwareReservations(ctx, key.projectID)
+		if err != nil {
+			logger.Error(err, "Could not inventory hardware reservations")
+			continue
+		}
+		reservations := make(map[string]*metalv1.HardwareReservation, len(list.HardwareReservations))
+		for j := range list.HardwareReservations {
+			reservations[list.HardwareReservations[j].GetId()] = &list.HardwareReservations[j]
+		}
+		for machineClass, reservationIDs := range project.machineClasses {
+			exportReservationMetrics(key.projectID, machineClass, reservationIDs, reservations)
+		}
+		logger.V(4).Info("Inventoried hardware reservations", "reservations", len(list.HardwareReservations), "machineClasses", len(project.machineClasses))
+	}
+}
+
+// exportReservationMetrics exports the number of total, used and idle reservations of the MachineClass by plan and
+// metro. Reservations unknown to the project are ignored, spares are not billed and must be activated by Equinix
+// Metal before they can be provisioned, so they are ignored as well.
+func exportReservationMetrics(projectID, machineClass string, reservationIDs []string, reservations map[string]*metalv1.HardwareReservation) {
+	type planMetro struct {
+		plan, metro string
+	}
+	var (
+		total = make(map[planMetro]int)
+		inUse = make(map[planMetro]int)
+		seen  = make(map[string]bool)
+	)
+	for _, id := range reservationIDs {
+		reservation, ok := reservations[id]
+		if !ok || seen[id] || reservation.GetSpare() {
+			continue
+		}
+		seen[id] = true
+		key := planMetro{plan: reservationPlan(reservation), metro: reservationMetro(reservation)}
+		total[key]++
+		if reservation.Device != nil {
+			inUse[key]++
+		}
+	}
+
+	// reservations may have been removed from the MachineClass since the last inventory
+	deleteReservationMetrics(projectID, machineClass)
+	for key, count := range total {
+		metrics.HardwareReservations.WithLabelValues(projectID, machineClass, key.plan, key.metro).Set(float64(count))
+		metrics.HardwareReservationsInUse.WithLabelValues(projectID, machineClass, key.plan, key.metro).Set(float64(inUse[key]))
+		metrics.HardwareReservationsIdle.WithLabelValues(projectID, machineClass, key.plan, key.metro).Set(float64(count - inUse[key]))
+	}
+}
+
+func deleteReservationMetrics(projectID, machineClass string) {
+	labels := prometheus.Labels{"project": projectID, "machine_class": machineClass}
+	metrics.HardwareReservations.DeletePartialMatch(labels)
+	metrics.HardwareReservationsInUse.DeletePartialMatch(labels)
+	metrics.HardwareReservationsIdle.DeletePartialMatch(labels)
+}
+
+func reservationPlan(reservation *metalv1.HardwareReservation) string {
+	if reservation.Plan == nil {
+		return ""
+	}
+	return reservation.Plan.GetSlug()
+}
+
+func reservationMetro(reservation *metalv1.HardwareReservation) string {
+	if reservation.Facility == nil || reservation.Facility.Metro == nil {
+		return ""
+	}
+	return reservation.Facility.Metro.GetCode()
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("HardwareReservations", func() {
+	var (
+		ctx    = context.Background()
+		plugin *mock.PluginSPIImpl
+	)
+	providerSecret := newProviderSecret()
+	newReservation := func(id, plan, metro string, inUse, spare bool) metalv1.HardwareReservation {
+		reservation := metalv1.HardwareReservation{
+			Id:       &id,
+			Plan:     &metalv1.Plan{Slug: &plan},
+			Facility: &metalv1.Facility{Metro: &metalv1.DeviceMetro{Code: &metro}},
+			Spare:    &spare,
+		}
+		if inUse {
+			reservation.Device = &metalv1.Device{Id: &id}
+		}
+		return reservation
+	}
+	newReservedMachineClassSpec := func(reservationIDs ...string) []byte {
+		return newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.ProjectID = "reserved-project"
+			spec.ReservationIDs = reservationIDs
+		})
+	}
+
+	BeforeEach(func() {
+		plugin = &mock.PluginSPIImpl{
+			HardwareReservations: []metalv1.HardwareReservation{
+				newReservation("res-1", "c3.small.x86", "ny", true, false),
+				newReservation("res-2", "c3.small.x86", "ny", false, false),
+				newReservation("res-3", "c3.small.x86", "ny", false, false),
+				newReservation("res-4", "m3.small.x86", "da", true, false),
+				newReservation("res-5", "m3.small.x86", "da", false, true),
+			},
+		}
+	})
+
+	It("should periodically export the utilisation of the reservations of the listed MachineClasses", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		p := provider.NewProvider(plugin, provider.WithReservationInventory(ctx, 10*time.Millisecond))
+		for _, machineClass := range []*v1alpha1.MachineClass{
+			setName(newMachineClass(newReservedMachineClassSpec("res-1", "res-2", "res-4", "res-5", "unknown")), "eqx-mc-reserved"),
+			setName(newMachineClass(newReservedMachineClassSpec()), "eqx-mc-on-demand"),
+		} {
+			_, err := p.ListMachines(ctx, &driver.ListMachinesRequest{
+				MachineClass: machineClass,
+				Secret:       providerSecret,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Eventually(func() float64 {
+			return testutil.ToFloat64(metrics.HardwareReservations.WithLabelValues("reserved-project", "eqx-mc-reserved", "c3.small.x86", "ny"))
+		}).Should(Equal(2.0))
+		Expect(testutil.ToFloat64(metrics.HardwareReservationsInUse.WithLabelValues("reserved-project", "eqx-mc-reserved", "c3.small.x86", "ny"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.HardwareReservationsIdle.WithLabelValues("reserved-project", "eqx-mc-reserved", "c3.small.x86", "ny"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.HardwareReservations.WithLabelValues("reserved-project", "eqx-mc-reserved", "m3.small.x86", "da"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.HardwareReservationsIdle.WithLabelValues("reserved-project", "eqx-mc-reserved", "m3.small.x86", "da"))).To(Equal(0.0))
+		Expect(metrics.HardwareReservations.DeletePartialMatch(prometheus.Labels{"machine_class": "eqx-mc-on-demand"})).To(BeZero())
+	})
+
+	It("should use the next reservation if one is in use", func() {
+		fallbacks := testutil.ToFloat64(metrics.ReservationOnDemandFallbacks.WithLabelValues("eqx-mc-reserved"))
+		p := provider.NewProvider(plugin)
+		_, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      newMachine(0),
+			MachineClass: setName(newMachineClass(newReservedMachineClassSpec("res-1", "res-2")), "eqx-mc-reserved"),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(plugin.HardwareReservations[1].Device).NotTo(BeNil())
+		Expect(testutil.ToFloat64(metrics.ReservationOnDemandFallbacks.WithLabelValues("eqx-mc-reserved"))).To(Equal(fallbacks))
+	})
+
+	It("should count the on-demand fallback if all reservations are in use", func() {
+		fallbacks := testutil.ToFloat64(metrics.ReservationOnDemandFallbacks.WithLabelValues("eqx-mc-reserved"))
+		p := provider.NewProvider(plugin)
+		_, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      newMachine(0),
+			MachineClass: setName(newMachineClass(newReservedMachineClassSpec("res-1", "res-4")), "eqx-mc-reserved"),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(plugin.Devices).To(HaveLen(1))
+		Expect(testutil.ToFloat64(metrics.ReservationOnDemandFallbacks.WithLabelValues("eqx-mc-reserved"))).To(Equal(fallbacks + 1))
+	})
+})
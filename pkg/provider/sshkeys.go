@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+)
+
+// sshKeysFingerprint returns a fingerprint of the SSH keys selected by the provider spec, where projectKeys are the
+// IDs of the selected project SSH keys
+func sshKeysFingerprint(providerSpec *api.EquinixMetalProviderSpec, projectKeys []string) string {
+	selection := "none"
+	if !providerSpec.NoSSHKeys {
+		projectKeys = append([]string(nil), projectKeys...)
+		userKeys := append([]string(nil), providerSpec.UserSSHKeys...)
+		sort.Strings(projectKeys)
+		sort.Strings(userKeys)
+		selection = strings.Join(projectKeys, ",") + ";" + strings.Join(userKeys, ",")
+	}
+	sum := sha256.Sum256([]byte(selection))
+	return hex.EncodeToString(sum[:8])
+}
+
+// sshKeysTag returns the SSH keys fingerprint recorded in the device tags, or an empty string
+func sshKeysTag(tags []string) string {
+	for _, tag := range api.ParseTags(tags) {
+		if tag.Key == api.TagKeySSHKeys {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// withSSHKeysTag returns the device tags with the SSH keys tag set to the fingerprint
+func withSSHKeysTag(tags []string, fingerprint string) []string {
+	result := make([]string, 0, len(tags)+1)
+	for _, tag := range tags {
+		if api.ParseTag(tag).Key != api.TagKeySSHKeys {
+			result = append(result, tag)
+		}
+	}
+	return append(result, api.Tag{Key: api.TagKeySSHKeys, Value: fingerprint}.String())
+}
+
+// projectSSHKeys returns the IDs of the project SSH keys of the provider spec followed by the IDs of the SSH keys of
+// the given project selected by label. The project keys are only listed if labels are selected, every label must select a key.
+func projectSSHKeys(ctx context.Context, svc spi.MetalDeviceService, providerSpec *api.EquinixMetalProviderSpec, projectID string) ([]string, error) {
+	if len(providerSpec.SSHKeyLabels) == 0 {
+		return providerSpec.SSHKeys, nil
+	}
+
+	list, _, err := svc.FindProjectSSHKeys(ctx, projectID)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, fmt.Sprintf("Could not list SSH keys of project %s: %v", projectID, err))
+	}
+	byLabel := make(map[string][]string)
+	for _, key := range list.SshKeys {
+		byLabel[key.GetLabel()] = append(byLabel[key.GetLabel()], key.GetId())
+	}
+
+	var (
+		ids  = append([]string(nil), providerSpec.SSHKeys...)
+		seen = make(map[string]bool)
+	)
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, label := range providerSpec.SSHKeyLabels {
+		selected, ok := byLabel[label]
+		if !ok {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("No SSH key with label %q in project %s", label, projectID))
+		}
+		for _, id := range selected {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	klog.FromContext(ctx).V(3).Info("Selected project SSH keys by label", "labels", providerSpec.SSHKeyLabels, "sshKeys", ids)
+	return ids, nil
+}
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SSHKeys", func() {
+	const userID = "b3d0f9a4-61c2-4d0e-9d6c-2f3c1a7e5b90"
+	newKey := func(id, label string) metalv1.SSHKey {
+		return metalv1.SSHKey{Id: &id, Label: &label}
+	}
+	newPlugin := func() *mock.PluginSPIImpl {
+		return &mock.PluginSPIImpl{
+			SSHKeys: []metalv1.SSHKey{
+				newKey("key-1", "ops"),
+				newKey("key-2", "ops"),
+				newKey("key-3", "ci"),
+				newKey("key-4", "alice"),
+			},
+		}
+	}
+
+	table.DescribeTable("should inject the selected keys",
+		func(modify func(spec *api.EquinixMetalProviderSpec), expected []string) {
+			plugin := newPlugin()
+			_, err := createMachineWith(plugin, newProviderSpec(modify), newProviderSecret())
+			Expect(err).NotTo(HaveOccurred())
+			var keys []string
+			for _, key := range plugin.Devices[0].SshKeys {
+				keys = append(keys, key.Href)
+			}
+			Expect(keys).To(Equal(expected))
+		},
+		table.Entry("all project keys by default", func(spec *api.EquinixMetalProviderSpec) {},
+			[]string{"/metal/v1/ssh-keys/key-1", "/metal/v1/ssh-keys/key-2", "/metal/v1/ssh-keys/key-3", "/metal/v1/ssh-keys/key-4"}),
+		table.Entry("no keys", func(spec *api.EquinixMetalProviderSpec) { spec.NoSSHKeys = true }, nil),
+		table.Entry("keys by ID", func(spec *api.EquinixMetalProviderSpec) { spec.SSHKeys = []string{"key-3"} },
+			[]string{"/metal/v1/ssh-keys/key-3"}),
+		table.Entry("keys by label", func(spec *api.EquinixMetalProviderSpec) { spec.SSHKeyLabels = []string{"ops"} },
+			[]string{"/metal/v1/ssh-keys/key-1", "/metal/v1/ssh-keys/key-2"}),
+		table.Entry("keys by ID and label without duplicates", func(spec *api.EquinixMetalProviderSpec) {
+			spec.SSHKeys = []string{"key-2"}
+			spec.SSHKeyLabels = []string{"ops", "ci"}
+		}, []string{"/metal/v1/ssh-keys/key-2", "/metal/v1/ssh-keys/key-1", "/metal/v1/ssh-keys/key-3"}),
+		table.Entry("user keys only", func(spec *api.EquinixMetalProviderSpec) { spec.UserSSHKeys = []string{userID} },
+			[]string{"/metal/v1/users/" + userID}),
+	)
+
+	It("should fail for labels without key", func() {
+		plugin := newPlugin()
+		_, err := createMachineWith(plugin, newProviderSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.SSHKeyLabels = []string{"ops", "bob"}
+		}), newProviderSecret())
+		Expect(err).To(MatchError(ContainSubstring(`No SSH key with label "bob"`)))
+		Expect(plugin.Devices).To(BeEmpty())
+	})
+})
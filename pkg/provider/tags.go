@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"fmt"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+)
+
+// deviceTags returns the static tags and labels of the provider spec, followed by the derived tags.
+func deviceTags(providerSpec *api.EquinixMetalProviderSpec, derived []string) []string {
+	return append(api.FormatTags(providerSpec.AllTags()), derived...)
+}
+
+// derivedTags returns the tags derived from the Machine and MachineClass metadata as configured in
+// providerSpec.DerivedTags.
+func derivedTags(providerSpec *api.EquinixMetalProviderSpec, machine *v1alpha1.Machine, machineClass *v1alpha1.MachineClass) []string {
+	var (
+		tags    []api.Tag
+		derived = providerSpec.DerivedTags
+	)
+	if derived == nil {
+		return nil
+	}
+	if derived.MachineName {
+		tags = append(tags, api.Tag{Key: api.TagKeyMachineName, Value: machine.Name})
+	}
+	if derived.Namespace {
+		tags = append(tags, api.Tag{Key: api.TagKeyNamespace, Value: machine.Namespace})
+	}
+	if derived.MachineClass {
+		tags = append(tags, api.Tag{Key: api.TagKeyMachineClass, Value: machineClass.Name})
+	}
+	for _, key := range derived.MachineLabels {
+		if value, ok := machine.Labels[key]; ok {
+			tags = append(tags, api.Tag{Key: key, Value: value})
+		}
+	}
+	return api.FormatTags(tags)
+}
+
+// deviceDescription generates the description shown for a device in the Equinix Metal console.
+func deviceDescription(machine *v1alpha1.Machine, machineClass *v1alpha1.MachineClass) string {
+	return fmt.Sprintf("Machine %s/%s of MachineClass %s, managed by the Gardener machine-controller-manager",
+		machine.Namespace, machine.Name, machineClass.Name)
+}
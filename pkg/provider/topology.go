@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/validation"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+)
+
+// stampTopology records the metro and facility of the device in its tags and customdata once the facility is known,
+// so that the node can read its topology from the metadata service. Failures are logged only and retried with the
+// next status check. It returns the updated device.
+func (p *Provider) stampTopology(ctx context.Context, svc spi.MetalDeviceService, device *metalv1.Device) *metalv1.Device {
+	metro, facility := device.Metro.GetCode(), device.Facility.GetCode()
+	if metro == "" || facility == "" {
+		return device
+	}
+	id, value := device.GetId(), metro+"/"+facility
+	p.mu.Lock()
+	stamped := p.topologies[id] == value
+	p.mu.Unlock()
+	if stamped || hasTopology(device, metro, facility) {
+		return device
+	}
+
+	logger := klog.FromContext(ctx).WithValues(logging.KeyMetro, metro, "facility", facility)
+	// the device may come from a listing, so it is read again to not overwrite tags or customdata written since
+	current, _, err := svc.FindDeviceByID(ctx, id)
+	if err != nil {
+		logger.Error(err, "Could not get device to stamp its topology")
+		return device
+	}
+	if !hasTopology(current, metro, facility) {
+		customData, err := stampedCustomData(current.Customdata, metro, facility)
+		if err != nil {
+			logger.Error(err, "Could not stamp device topology")
+			return device
+		}
+		if current, _, err = svc.UpdateDevice(ctx, id, metalv1.DeviceUpdateInput{
+			Tags:       withTopologyTags(current.Tags, metro, facility),
+			Customdata: customData,
+		}); err != nil {
+			logger.Error(err, "Could not stamp device topology")
+			return device
+		}
+		logger.V(3).Info("Stamped device topology")
+	}
+	p.mu.Lock()
+	p.topologies[id] = value
+	p.mu.Unlock()
+	return current
+}
+
+// hasTopology returns whether the tags and customdata of the device carry the metro and facility
+func hasTopology(device *metalv1.Device, metro, facility string) bool {
+	found := 0
+	for _, tag := range api.ParseTags(device.Tags) {
+		if (tag.Key == api.TagKeyMetro && tag.Value == metro) || (tag.Key == api.TagKeyFacility && tag.Value == facility) {
+			found++
+		}
+	}
+	topology, _ := device.Customdata[api.CustomDataKeyTopology].(map[string]interface{})
+	return found == 2 && topology["metro"] == metro && topology["facility"] == facility
+}
+
+// withTopologyTags returns the device tags with the metro and facility tags set, an empty facility is omitted
+func withTopologyTags(tags []string, metro, facility string) []string {
+	result := make([]string, 0, len(tags)+2)
+	for _, tag := range tags {
+		if key := api.ParseTag(tag).Key; key != api.TagKeyMetro && key != api.TagKeyFacility {
+			result = append(result, tag)
+		}
+	}
+	result = append(result, api.Tag{Key: api.TagKeyMetro, Value: metro}.String())
+	if facility != "" {
+		result = append(result, api.Tag{Key: api.TagKeyFacility, Value: facility}.String())
+	}
+	return result
+}
+
+// withTopologyCustomData returns a copy of the customdata with the topology object set to the metro and facility, an
+// empty facility is omitted
+func withTopologyCustomData(customData map[string]interface{}, metro, facility string) map[string]interface{} {
+	topology := map[string]interface{}{"metro": metro}
+	if facility != "" {
+		topology["facility"] = facility
+	}
+	result := make(map[string]interface{}, len(customData)+1)
+	for key, value := range customData {
+		result[key] = value
+	}
+	result[api.CustomDataKeyTopology] = topology
+	return result
+}
+
+// stampedCustomData returns a copy of the customdata with the topology object set to the metro and facility, or an
+// error if the stamped customdata exceeds the maximum length
+func stampedCustomData(customData map[string]interface{}, metro, facility string) (map[string]interface{}, error) {
+	result := withTopologyCustomData(customData, metro, facility)
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if errs := validation.ValidateCustomDataLength(raw, field.NewPath("customData")); len(errs) > 0 {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid customData with topology: %v", errs.ToAggregate().Error()))
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/validation"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Topology", func() {
+	providerSecret := newProviderSecret()
+	providerSpec := newProviderSpec(nil)
+
+	var (
+		plugin *mock.PluginSPIImpl
+		p      driver.Driver
+	)
+	getMachineStatus := func() {
+		machine := newMachine(1)
+		machine.Spec.ProviderID = "equinixmetal://ny/000001"
+		_, err := p.GetMachineStatus(context.Background(), &driver.GetMachineStatusRequest{
+			Machine:      machine,
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		plugin = &mock.PluginSPIImpl{}
+		p = provider.NewProvider(plugin)
+		_, err := p.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+			Machine:      newMachine(1),
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		plugin.Devices[0].Customdata["role"] = "worker"
+	})
+
+	It("should stamp the metro on creation", func() {
+		Expect(plugin.Devices[0].Tags).To(ContainElement("equinixmetal.gardener.cloud/metro: ny"))
+		Expect(plugin.Devices[0].Tags).NotTo(ContainElement(HavePrefix("equinixmetal.gardener.cloud/facility:")))
+		Expect(plugin.Devices[0].Customdata).To(HaveKeyWithValue("topology", map[string]interface{}{"metro": "ny"}))
+
+		getMachineStatus()
+		Expect(plugin.Devices[0].Tags).NotTo(ContainElement(HavePrefix("equinixmetal.gardener.cloud/facility:")))
+	})
+
+	It("should stamp the facility once the device is provisioned", func() {
+		facility := "ny5"
+		plugin.Devices[0].Facility = &metalv1.Facility{Code: &facility}
+		getMachineStatus()
+		getMachineStatus()
+
+		Expect(plugin.Devices[0].Tags).To(Equal([]string{
+			"kubernetes.io/cluster/shoot-test: 1",
+			"kubernetes.io/role/test: 1",
+			"equinixmetal.gardener.cloud/provider-id-format: metro",
+			"equinixmetal.gardener.cloud/metro: ny",
+			"equinixmetal.gardener.cloud/facility: ny5",
+		}))
+		Expect(plugin.Devices[0].Customdata).To(Equal(map[string]interface{}{
+			"role":     "worker",
+			"topology": map[string]interface{}{"metro": "ny", "facility": "ny5"},
+		}))
+	})
+
+	It("should keep the tags and customdata written since the device was read", func() {
+		facility := "ny5"
+		plugin.Devices[0].Facility = &metalv1.Facility{Code: &facility}
+		plugin.AfterFindDevice = func(device *metalv1.Device) {
+			device.Tags = append(device.Tags, "concurrent: 1")
+			device.Customdata["concurrent"] = "1"
+			plugin.AfterFindDevice = nil
+		}
+		getMachineStatus()
+
+		Expect(plugin.Devices[0].Tags).To(ContainElements("equinixmetal.gardener.cloud/facility: ny5", "concurrent: 1"))
+		Expect(plugin.Devices[0].Customdata).To(HaveKeyWithValue("concurrent", "1"))
+	})
+
+	It("should not stamp the facility if the customdata would exceed its maximum length", func() {
+		facility := "ny5"
+		plugin.Devices[0].Facility = &metalv1.Facility{Code: &facility}
+		plugin.Devices[0].Customdata["padding"] = strings.Repeat("x", validation.CustomDataMaxLength-50)
+		getMachineStatus()
+
+		Expect(plugin.Devices[0].Tags).NotTo(ContainElement(HavePrefix("equinixmetal.gardener.cloud/facility:")))
+		Expect(plugin.Devices[0].Customdata).To(HaveKeyWithValue("topology", map[string]interface{}{"metro": "ny"}))
+	})
+})
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"context"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/tracing"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		ctx      = context.Background()
+		exporter *tracetest.InMemoryExporter
+		p        driver.Driver
+	)
+	providerSecret := newProviderSecret()
+	providerSpec := newProviderSpec(nil)
+	spanNamed := func(name string) tracetest.SpanStub {
+		for _, span := range exporter.GetSpans() {
+			if span.Name == name {
+				return span
+			}
+		}
+		Fail("no span named " + name)
+		return tracetest.SpanStub{}
+	}
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		p = provider.NewProvider(spi.NewInstrumentedSessionProvider(&mock.PluginSPIImpl{}))
+	})
+	AfterEach(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+
+	It("should trace the driver call with child spans of the device service calls", func() {
+		_, err := p.CreateMachine(ctx, &driver.CreateMachineRequest{
+			Machine:      newMachine(0),
+			MachineClass: setName(newMachineClass(providerSpec), "eqx-mc"),
+			Secret:       providerSecret,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		createMachine := spanNamed("CreateMachine")
+		Expect(createMachine.Attributes).To(ContainElements(
+			tracing.AttributeMachine.String("machine-0"),
+			tracing.AttributeMachineClass.String("eqx-mc"),
+		))
+		createDevice := spanNamed("MetalDeviceService.CreateDevice")
+		Expect(createDevice.Parent.SpanID()).To(Equal(createMachine.SpanContext.SpanID()))
+		Expect(createDevice.SpanKind).To(Equal(trace.SpanKindClient))
+		Expect(createDevice.Attributes).To(ContainElements(
+			tracing.AttributeProjectID.String("abcdefg"),
+			tracing.AttributeDeviceID.String("000001"),
+		))
+		Expect(spanNamed("MetalDeviceService.CheckCapacity").Parent.SpanID()).To(Equal(createMachine.SpanContext.SpanID()))
+	})
+
+	It("should record errors on the spans", func() {
+		_, err := p.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+			Machine:      newMachine(0),
+			MachineClass: newMachineClass(providerSpec),
+			Secret:       providerSecret,
+		})
+		Expect(err).To(HaveOccurred())
+
+		Expect(spanNamed("GetMachineStatus").Status.Code).To(Equal(codes.Error))
+		findDevice := spanNamed("MetalDeviceService.FindDeviceByID")
+		Expect(findDevice.Status.Code).To(Equal(codes.Error))
+		Expect(findDevice.Attributes).To(ContainElement(attribute.Int("http.status_code", 404)))
+	})
+})
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package spi
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+)
+
+// RebootDevice power-cycles the device.
+func RebootDevice(ctx context.Context, svc MetalDeviceService, deviceID string) (*http.Response, error) {
+	return performAction(ctx, svc, deviceID, metalv1.DEVICEACTIONINPUTTYPE_REBOOT)
+}
+
+// PowerOffDevice powers the device off.
+func PowerOffDevice(ctx context.Context, svc MetalDeviceService, deviceID string) (*http.Response, error) {
+	return performAction(ctx, svc, deviceID, metalv1.DEVICEACTIONINPUTTYPE_POWER_OFF)
+}
+
+// PowerOnDevice powers the device on.
+func PowerOnDevice(ctx context.Context, svc MetalDeviceService, deviceID string) (*http.Response, error) {
+	return performAction(ctx, svc, deviceID, metalv1.DEVICEACTIONINPUTTYPE_POWER_ON)
+}
+
+// RescueDevice reboots the device into the rescue operating system, which runs from memory and keeps the disks intact.
+func RescueDevice(ctx context.Context, svc MetalDeviceService, deviceID string) (*http.Response, error) {
+	return performAction(ctx, svc, deviceID, metalv1.DEVICEACTIONINPUTTYPE_RESCUE)
+}
+
+func performAction(ctx context.Context, svc MetalDeviceService, deviceID string, action metalv1.DeviceActionInputType) (*http.Response, error) {
+	return svc.PerformAction(ctx, deviceID, metalv1.DeviceActionInput{Type: action})
+}
@@ -3,8 +3,10 @@ package spi
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/equinix/equinix-sdk-go/services/metalv1"
 	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
@@ -13,6 +15,55 @@ import (
 
 // PluginSPIImpl is the real implementation of SPI interface that makes the calls to the provider SDK.
 type PluginSPIImpl struct {
+	// Timeouts are the timeouts of the API calls by operation
+	Timeouts Timeouts
+	// APIURL is the URL of the Equinix Metal API, the default URL of the SDK is used if empty
+	APIURL string
+	// HTTPClient is the client used for the API calls, a client shared by all sessions is used if nil
+	HTTPClient *http.Client
+}
+
+// Timeouts are the maximum durations of the Equinix Metal API calls by operation, a zero timeout disables it.
+// Calls that are not listed fall into the operation closest to them, i.e. checking the capacity is a get,
+// listing plans, operating systems or hardware reservations is a list and updating a device or performing
+// an action on it is a create.
+type Timeouts struct {
+	Create time.Duration
+	Get    time.Duration
+	List   time.Duration
+	Delete time.Duration
+}
+
+// DefaultTimeouts are the default timeouts of the API calls
+var DefaultTimeouts = Timeouts{
+	Create: 60 * time.Second,
+	Get:    30 * time.Second,
+	List:   60 * time.Second,
+	Delete: 30 * time.Second,
+}
+
+// defaultHTTPClient is shared by all sessions, so that connections to the API are reused
+var defaultHTTPClient = NewHTTPClient()
+
+// NewHTTPClient returns an HTTP client whose connections are bounded by dial, TLS handshake, response header
+// and idle timeouts, so that a hung connection to the API does not block a call forever
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 60 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10,
+		},
+	}
 }
 
 // NewSession creates a session for equinix metal provider
@@ -27,9 +78,16 @@ func (p *PluginSPIImpl) NewSession(secret *corev1.Secret) (MetalDeviceService, e
 	configuration := metalv1.NewConfiguration()
 	configuration.Debug = true
 	configuration.AddDefaultHeader("X-Auth-Token", token)
+	configuration.HTTPClient = p.HTTPClient
+	if configuration.HTTPClient == nil {
+		configuration.HTTPClient = defaultHTTPClient
+	}
+	if p.APIURL != "" {
+		configuration.Servers = metalv1.ServerConfigurations{{URL: p.APIURL}}
+	}
 	client := metalv1.NewAPIClient(configuration)
 
-	return &metalDeviceSvc{client: client}, nil
+	return &metalDeviceSvc{client: client, timeouts: p.Timeouts}, nil
 }
 
 // GetAPIKey extracts the APIKey from the *corev1.Secret object
@@ -48,21 +106,84 @@ func extractCredentialsFromData(data map[string][]byte, keys ...string) string {
 	return ""
 }
 
+// maxPerPage is the maximum page size of the Equinix Metal API, so that a single request lists all hardware
+// reservations of a project and most projects need a single request for listing their devices
+const maxPerPage = 1000
+
+// nextPageHookKey is the context key of the function called before every further page of a listing is requested
+type nextPageHookKey struct{}
+
+// withNextPageHook returns a context calling the hook before every page of a listing after the first one is
+// requested, so that wrapping services account for every request of the listing
+func withNextPageHook(ctx context.Context, hook func(ctx context.Context) error) context.Context {
+	return context.WithValue(ctx, nextPageHookKey{}, hook)
+}
+
+// beforeNextPage calls the next page hook of the context, if any
+func beforeNextPage(ctx context.Context) error {
+	if hook, ok := ctx.Value(nextPageHookKey{}).(func(ctx context.Context) error); ok {
+		return hook(ctx)
+	}
+	return nil
+}
+
 type metalDeviceSvc struct {
-	client *metalv1.APIClient
+	client   *metalv1.APIClient
+	timeouts Timeouts
+}
+
+// withTimeout bounds the call by the timeout, the returned cancel function must be called once the call returned
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 func (a *metalDeviceSvc) FindProjectDevices(
 	ctx context.Context,
 	projectID string,
 ) (*metalv1.DeviceList, *http.Response, error) {
-	return a.client.DevicesApi.FindProjectDevices(ctx, projectID).Execute()
+	var (
+		devices = &metalv1.DeviceList{}
+		page    = int32(1)
+	)
+	// every page is requested with its own timeout, the last response is returned
+	for {
+		if page > 1 {
+			if err := beforeNextPage(ctx); err != nil {
+				return nil, nil, err
+			}
+		}
+		list, resp, err := a.findProjectDevicesPage(ctx, projectID, page)
+		if err != nil {
+			return nil, resp, err
+		}
+		devices.Devices = append(devices.Devices, list.Devices...)
+		if list.Meta == nil || list.Meta.GetLastPage() <= list.Meta.GetCurrentPage() {
+			devices.Meta = list.Meta
+			return devices, resp, nil
+		}
+		page = list.Meta.GetCurrentPage() + 1
+	}
+}
+
+func (a *metalDeviceSvc) findProjectDevicesPage(
+	ctx context.Context,
+	projectID string,
+	page int32,
+) (*metalv1.DeviceList, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.List)
+	defer cancel()
+	return a.client.DevicesApi.FindProjectDevices(ctx, projectID).Page(page).PerPage(maxPerPage).Execute()
 }
 
 func (a *metalDeviceSvc) FindDeviceByID(
 	ctx context.Context,
 	deviceID string,
 ) (*metalv1.Device, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.Get)
+	defer cancel()
 	return a.client.DevicesApi.FindDeviceById(ctx, deviceID).Execute()
 }
 
@@ -71,6 +192,8 @@ func (a *metalDeviceSvc) CreateDevice(
 	projectID string,
 	createDeviceRequest metalv1.CreateDeviceRequest,
 ) (*metalv1.Device, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.Create)
+	defer cancel()
 	return a.client.DevicesApi.
 		CreateDevice(ctx, projectID).
 		CreateDeviceRequest(createDeviceRequest).Execute()
@@ -80,5 +203,79 @@ func (a *metalDeviceSvc) DeleteDevice(
 	ctx context.Context,
 	deviceID string,
 ) (*http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.Delete)
+	defer cancel()
 	return a.client.DevicesApi.DeleteDevice(ctx, deviceID).Execute()
 }
+
+func (a *metalDeviceSvc) UpdateDevice(
+	ctx context.Context,
+	deviceID string,
+	updateDeviceInput metalv1.DeviceUpdateInput,
+) (*metalv1.Device, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.Create)
+	defer cancel()
+	return a.client.DevicesApi.
+		UpdateDevice(ctx, deviceID).
+		DeviceUpdateInput(updateDeviceInput).Execute()
+}
+
+func (a *metalDeviceSvc) PerformAction(
+	ctx context.Context,
+	deviceID string,
+	actionInput metalv1.DeviceActionInput,
+) (*http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.Create)
+	defer cancel()
+	return a.client.DevicesApi.
+		PerformAction(ctx, deviceID).
+		DeviceActionInput(actionInput).Execute()
+}
+
+func (a *metalDeviceSvc) CheckCapacity(
+	ctx context.Context,
+	servers []metalv1.ServerInfo,
+) (*metalv1.CapacityCheckPerMetroList, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.Get)
+	defer cancel()
+	return a.client.CapacityApi.
+		CheckCapacityForMetro(ctx).
+		CapacityInput(metalv1.CapacityInput{Servers: servers}).Execute()
+}
+
+func (a *metalDeviceSvc) FindPlans(
+	ctx context.Context,
+) (*metalv1.PlanList, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.List)
+	defer cancel()
+	return a.client.PlansApi.FindPlans(ctx).Execute()
+}
+
+func (a *metalDeviceSvc) FindOperatingSystems(
+	ctx context.Context,
+) (*metalv1.OperatingSystemList, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.List)
+	defer cancel()
+	return a.client.OperatingSystemsApi.FindOperatingSystems(ctx).Execute()
+}
+
+func (a *metalDeviceSvc) FindProjectHardwareReservations(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.HardwareReservationList, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.List)
+	defer cancel()
+	return a.client.HardwareReservationsApi.
+		FindProjectHardwareReservations(ctx, projectID).
+		Include([]string{"plan", "facility.metro"}).
+		PerPage(maxPerPage).Execute()
+}
+
+func (a *metalDeviceSvc) FindProjectSSHKeys(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.SSHKeyList, *http.Response, error) {
+	ctx, cancel := withTimeout(ctx, a.timeouts.List)
+	defer cancel()
+	return a.client.SSHKeysApi.FindProjectSSHKeys(ctx, projectID).Execute()
+}
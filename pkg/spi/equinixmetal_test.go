@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package spi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("PluginSPIImpl", func() {
+	var (
+		server  *httptest.Server
+		release chan struct{}
+	)
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"apiToken": []byte("dummy-token"),
+		},
+	}
+	newSession := func(timeouts spi.Timeouts) spi.MetalDeviceService {
+		svc, err := (&spi.PluginSPIImpl{Timeouts: timeouts, APIURL: server.URL}).NewSession(secret)
+		Expect(err).NotTo(HaveOccurred())
+		return svc
+	}
+
+	BeforeEach(func() {
+		release = make(chan struct{})
+		// the server answers requests for the device "fast" and the devices of the project "paged" immediately and
+		// hangs on all others
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/devices/fast") {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"id":"fast"}`))
+				return
+			}
+			if strings.HasSuffix(r.URL.Path, "/projects/paged/devices") {
+				page := r.URL.Query().Get("page")
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"devices":[{"id":"device-` + page + `"}],"meta":{"current_page":` + page + `,"last_page":3}}`))
+				return
+			}
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}))
+	})
+	AfterEach(func() {
+		close(release)
+		server.Close()
+	})
+
+	DescribeTable("should abort calls exceeding the timeout of their operation",
+		func(timeouts spi.Timeouts, call func(ctx context.Context, svc spi.MetalDeviceService) error) {
+			svc := newSession(timeouts)
+			start := time.Now()
+			err := call(context.Background(), svc)
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue(), "unexpected error %v", err)
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		},
+		Entry("create", spi.Timeouts{Create: 50 * time.Millisecond}, func(ctx context.Context, svc spi.MetalDeviceService) error {
+			_, _, err := svc.CreateDevice(ctx, "project", metalv1.CreateDeviceRequest{
+				DeviceCreateInMetroInput: &metalv1.DeviceCreateInMetroInput{Metro: "ny", Plan: "c3.small.x86"},
+			})
+			return err
+		}),
+		Entry("get", spi.Timeouts{Get: 50 * time.Millisecond}, func(ctx context.Context, svc spi.MetalDeviceService) error {
+			_, _, err := svc.FindDeviceByID(ctx, "slow")
+			return err
+		}),
+		Entry("list", spi.Timeouts{List: 50 * time.Millisecond}, func(ctx context.Context, svc spi.MetalDeviceService) error {
+			_, _, err := svc.FindProjectDevices(ctx, "project")
+			return err
+		}),
+		Entry("delete", spi.Timeouts{Delete: 50 * time.Millisecond}, func(ctx context.Context, svc spi.MetalDeviceService) error {
+			_, err := svc.DeleteDevice(ctx, "slow")
+			return err
+		}),
+		Entry("the deadline of the caller without timeout", spi.Timeouts{}, func(ctx context.Context, svc spi.MetalDeviceService) error {
+			ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			_, _, err := svc.FindDeviceByID(ctx, "slow")
+			return err
+		}),
+	)
+
+	It("should complete calls within the timeout", func() {
+		svc := newSession(spi.Timeouts{Get: 5 * time.Second})
+		device, _, err := svc.FindDeviceByID(context.Background(), "fast")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(device.GetId()).To(Equal("fast"))
+	})
+
+	It("should list the devices of all pages", func() {
+		svc := newSession(spi.Timeouts{List: 5 * time.Second})
+		list, _, err := svc.FindProjectDevices(context.Background(), "paged")
+		Expect(err).NotTo(HaveOccurred())
+		var ids []string
+		for _, device := range list.Devices {
+			ids = append(ids, device.GetId())
+		}
+		Expect(ids).To(Equal([]string{"device-1", "device-2", "device-3"}))
+	})
+
+	It("should charge the rate limit for every page", func() {
+		sessionProvider := spi.NewRateLimitedSessionProvider(&spi.PluginSPIImpl{APIURL: server.URL}, 1, 4, spi.Timeouts{List: time.Second})
+		svc, err := sessionProvider.NewSession(secret)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the bulk share of the burst covers the first two pages only
+		_, _, err = svc.FindProjectDevices(context.Background(), "paged")
+		Expect(err).To(MatchError(ContainSubstring("would exceed context deadline")))
+	})
+})
@@ -0,0 +1,277 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package spi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/logging"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// headerRateLimitRemaining is the response header of the Equinix Metal API carrying the remaining requests
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	// headerRequestID is the response header of the Equinix Metal API carrying the ID of the request
+	headerRequestID = "X-Request-Id"
+)
+
+// NewInstrumentedSessionProvider wraps the session provider, so that the calls of its device services
+// are recorded in the provider metrics and traced as child spans of the driver calls.
+func NewInstrumentedSessionProvider(sessionProvider SessionProviderInterface) SessionProviderInterface {
+	return &instrumentedSessionProvider{
+		sessionProvider: sessionProvider,
+		provisioning:    make(map[string]time.Time),
+	}
+}
+
+type instrumentedSessionProvider struct {
+	sessionProvider SessionProviderInterface
+
+	mu sync.Mutex
+	// provisioning contains the creation time of the devices created by this process that were not seen active yet
+	provisioning map[string]time.Time
+}
+
+// NewSession creates a session of the wrapped session provider with an instrumented device service
+func (p *instrumentedSessionProvider) NewSession(secret *corev1.Secret) (MetalDeviceService, error) {
+	svc, err := p.sessionProvider.NewSession(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedDeviceSvc{svc: svc, provider: p}, nil
+}
+
+func (p *instrumentedSessionProvider) created(device *metalv1.Device) {
+	if device == nil || device.Id == nil {
+		return
+	}
+	createdAt := time.Now()
+	if device.CreatedAt != nil {
+		createdAt = *device.CreatedAt
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.provisioning[*device.Id] = createdAt
+}
+
+func (p *instrumentedSessionProvider) observed(devices ...metalv1.Device) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, device := range devices {
+		if device.Id == nil || device.GetState() != metalv1.DEVICESTATE_ACTIVE {
+			continue
+		}
+		if createdAt, ok := p.provisioning[*device.Id]; ok {
+			metrics.DeviceProvisioningDuration.Observe(time.Since(createdAt).Seconds())
+			delete(p.provisioning, *device.Id)
+		}
+	}
+}
+
+func (p *instrumentedSessionProvider) deleted(deviceID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.provisioning, deviceID)
+}
+
+type instrumentedDeviceSvc struct {
+	svc      MetalDeviceService
+	provider *instrumentedSessionProvider
+}
+
+// logKeys maps the span attributes of the calls to the keys of their log messages
+var logKeys = map[attribute.Key]string{
+	tracing.AttributeProjectID: logging.KeyProjectID,
+	tracing.AttributeDeviceID:  logging.KeyDeviceID,
+	tracing.AttributeAction:    logging.KeyAction,
+}
+
+// begin starts the span of a call and adds the operation and the attributes to the logger of the context
+func begin(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span, time.Time) {
+	keysAndValues := []interface{}{logging.KeyOperation, operation}
+	for _, attr := range attrs {
+		if key, ok := logKeys[attr.Key]; ok {
+			keysAndValues = append(keysAndValues, key, attr.Value.Emit())
+		}
+	}
+	ctx, _ = logging.WithValues(ctx, keysAndValues...)
+	ctx, span := tracing.StartClient(ctx, "MetalDeviceService."+operation, attrs...)
+	return ctx, span, time.Now()
+}
+
+// record logs a finished call, records its request count, latency, error and rate limit metrics and ends its span
+func record(ctx context.Context, span trace.Span, operation string, start time.Time, resp *http.Response, err error) {
+	defer func() { tracing.End(span, err) }()
+
+	duration := time.Since(start)
+	metrics.APIRequests.WithLabelValues(operation).Inc()
+	metrics.APIRequestDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		code := "none"
+		if resp != nil && resp.StatusCode != 0 {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		metrics.APIRequestErrors.WithLabelValues(operation, code).Inc()
+	}
+
+	keysAndValues := []interface{}{"duration", duration}
+	if resp != nil {
+		if resp.StatusCode != 0 {
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+			keysAndValues = append(keysAndValues, "statusCode", resp.StatusCode)
+		}
+		if requestID := resp.Header.Get(headerRequestID); requestID != "" {
+			span.SetAttributes(tracing.AttributeRequestID.String(requestID))
+			keysAndValues = append(keysAndValues, logging.KeyRequestID, requestID)
+		}
+		if remaining, err := strconv.Atoi(resp.Header.Get(headerRateLimitRemaining)); err == nil {
+			metrics.APIRateLimitRemaining.Set(float64(remaining))
+		}
+	}
+	if err != nil {
+		klog.FromContext(ctx).V(2).Info("Equinix Metal API call failed", append(keysAndValues, "err", err)...)
+		return
+	}
+	klog.FromContext(ctx).V(4).Info("Equinix Metal API call succeeded", keysAndValues...)
+}
+
+func (i *instrumentedDeviceSvc) FindProjectDevices(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.DeviceList, *http.Response, error) {
+	ctx, span, start := begin(ctx, "FindProjectDevices", tracing.AttributeProjectID.String(projectID))
+	list, resp, err := i.svc.FindProjectDevices(ctx, projectID)
+	record(ctx, span, "FindProjectDevices", start, resp, err)
+	if err == nil && list != nil {
+		i.provider.observed(list.Devices...)
+	}
+	return list, resp, err
+}
+
+func (i *instrumentedDeviceSvc) FindDeviceByID(
+	ctx context.Context,
+	deviceID string,
+) (*metalv1.Device, *http.Response, error) {
+	ctx, span, start := begin(ctx, "FindDeviceByID", tracing.AttributeDeviceID.String(deviceID))
+	device, resp, err := i.svc.FindDeviceByID(ctx, deviceID)
+	record(ctx, span, "FindDeviceByID", start, resp, err)
+	if err == nil && device != nil {
+		i.provider.observed(*device)
+	}
+	return device, resp, err
+}
+
+func (i *instrumentedDeviceSvc) CreateDevice(
+	ctx context.Context,
+	projectID string,
+	createDeviceRequest metalv1.CreateDeviceRequest,
+) (*metalv1.Device, *http.Response, error) {
+	ctx, span, start := begin(ctx, "CreateDevice", tracing.AttributeProjectID.String(projectID))
+	device, resp, err := i.svc.CreateDevice(ctx, projectID, createDeviceRequest)
+	if device != nil {
+		span.SetAttributes(tracing.AttributeDeviceID.String(device.GetId()))
+	}
+	record(ctx, span, "CreateDevice", start, resp, err)
+	if err == nil {
+		i.provider.created(device)
+	}
+	return device, resp, err
+}
+
+func (i *instrumentedDeviceSvc) DeleteDevice(
+	ctx context.Context,
+	deviceID string,
+) (*http.Response, error) {
+	ctx, span, start := begin(ctx, "DeleteDevice", tracing.AttributeDeviceID.String(deviceID))
+	resp, err := i.svc.DeleteDevice(ctx, deviceID)
+	record(ctx, span, "DeleteDevice", start, resp, err)
+	if err == nil {
+		i.provider.deleted(deviceID)
+	}
+	return resp, err
+}
+
+func (i *instrumentedDeviceSvc) UpdateDevice(
+	ctx context.Context,
+	deviceID string,
+	updateDeviceInput metalv1.DeviceUpdateInput,
+) (*metalv1.Device, *http.Response, error) {
+	ctx, span, start := begin(ctx, "UpdateDevice", tracing.AttributeDeviceID.String(deviceID))
+	device, resp, err := i.svc.UpdateDevice(ctx, deviceID, updateDeviceInput)
+	record(ctx, span, "UpdateDevice", start, resp, err)
+	return device, resp, err
+}
+
+func (i *instrumentedDeviceSvc) PerformAction(
+	ctx context.Context,
+	deviceID string,
+	actionInput metalv1.DeviceActionInput,
+) (*http.Response, error) {
+	ctx, span, start := begin(ctx, "PerformAction", tracing.AttributeDeviceID.String(deviceID),
+		tracing.AttributeAction.String(string(actionInput.Type)))
+	resp, err := i.svc.PerformAction(ctx, deviceID, actionInput)
+	record(ctx, span, "PerformAction", start, resp, err)
+	return resp, err
+}
+
+func (i *instrumentedDeviceSvc) CheckCapacity(
+	ctx context.Context,
+	servers []metalv1.ServerInfo,
+) (*metalv1.CapacityCheckPerMetroList, *http.Response, error) {
+	ctx, span, start := begin(ctx, "CheckCapacity")
+	list, resp, err := i.svc.CheckCapacity(ctx, servers)
+	record(ctx, span, "CheckCapacity", start, resp, err)
+	return list, resp, err
+}
+
+func (i *instrumentedDeviceSvc) FindPlans(
+	ctx context.Context,
+) (*metalv1.PlanList, *http.Response, error) {
+	ctx, span, start := begin(ctx, "FindPlans")
+	list, resp, err := i.svc.FindPlans(ctx)
+	record(ctx, span, "FindPlans", start, resp, err)
+	return list, resp, err
+}
+
+func (i *instrumentedDeviceSvc) FindOperatingSystems(
+	ctx context.Context,
+) (*metalv1.OperatingSystemList, *http.Response, error) {
+	ctx, span, start := begin(ctx, "FindOperatingSystems")
+	list, resp, err := i.svc.FindOperatingSystems(ctx)
+	record(ctx, span, "FindOperatingSystems", start, resp, err)
+	return list, resp, err
+}
+
+func (i *instrumentedDeviceSvc) FindProjectHardwareReservations(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.HardwareReservationList, *http.Response, error) {
+	ctx, span, start := begin(ctx, "FindProjectHardwareReservations", tracing.AttributeProjectID.String(projectID))
+	list, resp, err := i.svc.FindProjectHardwareReservations(ctx, projectID)
+	record(ctx, span, "FindProjectHardwareReservations", start, resp, err)
+	return list, resp, err
+}
+
+func (i *instrumentedDeviceSvc) FindProjectSSHKeys(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.SSHKeyList, *http.Response, error) {
+	ctx, span, start := begin(ctx, "FindProjectSSHKeys", tracing.AttributeProjectID.String(projectID))
+	list, resp, err := i.svc.FindProjectSSHKeys(ctx, projectID)
+	record(ctx, span, "FindProjectSSHKeys", start, resp, err)
+	return list, resp, err
+}
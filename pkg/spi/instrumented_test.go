@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package spi_test
+
+import (
+	"context"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/metrics"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("InstrumentedSessionProvider", func() {
+	var (
+		ctx           = context.Background()
+		mockPluginSPI *mock.PluginSPIImpl
+		svc           spi.MetalDeviceService
+	)
+
+	sampleCount := func(h prometheus.Histogram) uint64 {
+		m := &dto.Metric{}
+		Expect(h.Write(m)).To(Succeed())
+		return m.GetHistogram().GetSampleCount()
+	}
+
+	BeforeEach(func() {
+		var err error
+		mockPluginSPI = &mock.PluginSPIImpl{}
+		svc, err = spi.NewInstrumentedSessionProvider(mockPluginSPI).NewSession(&corev1.Secret{
+			Data: map[string][]byte{"apiToken": []byte("dummy-token")},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should count requests and errors by status code", func() {
+		requests := testutil.ToFloat64(metrics.APIRequests.WithLabelValues("FindDeviceByID"))
+		notFound := testutil.ToFloat64(metrics.APIRequestErrors.WithLabelValues("FindDeviceByID", "404"))
+
+		_, _, err := svc.FindDeviceByID(ctx, "missing")
+		Expect(err).To(HaveOccurred())
+
+		Expect(testutil.ToFloat64(metrics.APIRequests.WithLabelValues("FindDeviceByID"))).To(Equal(requests + 1))
+		Expect(testutil.ToFloat64(metrics.APIRequestErrors.WithLabelValues("FindDeviceByID", "404"))).To(Equal(notFound + 1))
+	})
+
+	It("should observe the provisioning duration once the device is active", func() {
+		provisioned := sampleCount(metrics.DeviceProvisioningDuration)
+
+		device, _, err := svc.CreateDevice(ctx, "abcdefg", metalv1.CreateDeviceRequest{
+			DeviceCreateInMetroInput: &metalv1.DeviceCreateInMetroInput{
+				Metro:           "ny",
+				Plan:            "c3.small.x86",
+				OperatingSystem: "alpine_3",
+				BillingCycle:    metalv1.DEVICECREATEINPUTBILLINGCYCLE_HOURLY.Ptr(),
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = svc.FindDeviceByID(ctx, device.GetId())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sampleCount(metrics.DeviceProvisioningDuration)).To(Equal(provisioned))
+
+		mockPluginSPI.Devices[0].State = metalv1.DEVICESTATE_ACTIVE.Ptr()
+		_, _, err = svc.FindDeviceByID(ctx, device.GetId())
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = svc.FindProjectDevices(ctx, "abcdefg")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sampleCount(metrics.DeviceProvisioningDuration)).To(Equal(provisioned + 1))
+	})
+})
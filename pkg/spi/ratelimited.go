@@ -0,0 +1,218 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package spi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/equinix/equinix-sdk-go/services/metalv1"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// bulkShare is the share of the rate limit bulk calls like listing the devices of a project may use at most,
+// so that the remainder is always available for the calls MCM depends on, like getting or deleting a device
+const bulkShare = 0.5
+
+// NewRateLimitedSessionProvider wraps the session provider, so that the calls of all its device services using the
+// same API token are limited by a token bucket with the given rate and burst. Bulk calls listing resources may only
+// use a share of the rate, so that deleting and getting devices take priority over them. The burst must be at least 1.
+// A call waits for the limiter at most the timeout of its operation.
+func NewRateLimitedSessionProvider(sessionProvider SessionProviderInterface, qps float64, burst int, timeouts Timeouts) SessionProviderInterface {
+	return &rateLimitedSessionProvider{
+		sessionProvider: sessionProvider,
+		qps:             qps,
+		burst:           burst,
+		timeouts:        timeouts,
+		limiters:        make(map[string]*tokenLimiters),
+	}
+}
+
+type rateLimitedSessionProvider struct {
+	sessionProvider SessionProviderInterface
+	qps             float64
+	burst           int
+	timeouts        Timeouts
+
+	mu sync.Mutex
+	// limiters contains the limiters by API token, as the rate limit of the API applies per token
+	limiters map[string]*tokenLimiters
+}
+
+// tokenLimiters are the limiters of an API token
+type tokenLimiters struct {
+	// all limits all calls
+	all *rate.Limiter
+	// bulk additionally limits the bulk calls
+	bulk *rate.Limiter
+}
+
+// NewSession creates a session of the wrapped session provider with a rate limited device service
+func (p *rateLimitedSessionProvider) NewSession(secret *corev1.Secret) (MetalDeviceService, error) {
+	svc, err := p.sessionProvider.NewSession(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimitedDeviceSvc{svc: svc, limiters: p.limitersOf(GetAPIKey(secret)), timeouts: p.timeouts}, nil
+}
+
+func (p *rateLimitedSessionProvider) limitersOf(token string) *tokenLimiters {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	limiters, ok := p.limiters[token]
+	if !ok {
+		bulkBurst := int(float64(p.burst) * bulkShare)
+		if bulkBurst < 1 {
+			bulkBurst = 1
+		}
+		limiters = &tokenLimiters{
+			all:  rate.NewLimiter(rate.Limit(p.qps), p.burst),
+			bulk: rate.NewLimiter(rate.Limit(p.qps*bulkShare), bulkBurst),
+		}
+		p.limiters[token] = limiters
+	}
+	return limiters
+}
+
+type rateLimitedDeviceSvc struct {
+	svc      MetalDeviceService
+	limiters *tokenLimiters
+	timeouts Timeouts
+}
+
+// wait blocks until the call may be made, the timeout elapsed or the context is done
+func (r *rateLimitedDeviceSvc) wait(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	return r.limiters.all.Wait(ctx)
+}
+
+// waitBulk blocks until the bulk call may be made, the timeout elapsed or the context is done
+func (r *rateLimitedDeviceSvc) waitBulk(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	if err := r.limiters.bulk.Wait(ctx); err != nil {
+		return err
+	}
+	return r.limiters.all.Wait(ctx)
+}
+
+func (r *rateLimitedDeviceSvc) FindProjectDevices(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.DeviceList, *http.Response, error) {
+	wait := func(ctx context.Context) error {
+		return r.waitBulk(ctx, r.timeouts.List)
+	}
+	if err := wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	// every further page is a request of its own
+	return r.svc.FindProjectDevices(withNextPageHook(ctx, wait), projectID)
+}
+
+func (r *rateLimitedDeviceSvc) FindDeviceByID(
+	ctx context.Context,
+	deviceID string,
+) (*metalv1.Device, *http.Response, error) {
+	if err := r.wait(ctx, r.timeouts.Get); err != nil {
+		return nil, nil, err
+	}
+	return r.svc.FindDeviceByID(ctx, deviceID)
+}
+
+func (r *rateLimitedDeviceSvc) CreateDevice(
+	ctx context.Context,
+	projectID string,
+	createDeviceRequest metalv1.CreateDeviceRequest,
+) (*metalv1.Device, *http.Response, error) {
+	if err := r.wait(ctx, r.timeouts.Create); err != nil {
+		return nil, nil, err
+	}
+	return r.svc.CreateDevice(ctx, projectID, createDeviceRequest)
+}
+
+func (r *rateLimitedDeviceSvc) DeleteDevice(
+	ctx context.Context,
+	deviceID string,
+) (*http.Response, error) {
+	if err := r.wait(ctx, r.timeouts.Delete); err != nil {
+		return nil, err
+	}
+	return r.svc.DeleteDevice(ctx, deviceID)
+}
+
+func (r *rateLimitedDeviceSvc) UpdateDevice(
+	ctx context.Context,
+	deviceID string,
+	updateDeviceInput metalv1.DeviceUpdateInput,
+) (*metalv1.Device, *http.Response, error) {
+	if err := r.wait(ctx, r.timeouts.Create); err != nil {
+		return nil, nil, err
+	}
+	return r.svc.UpdateDevice(ctx, deviceID, updateDeviceInput)
+}
+
+func (r *rateLimitedDeviceSvc) PerformAction(
+	ctx context.Context,
+	deviceID string,
+	actionInput metalv1.DeviceActionInput,
+) (*http.Response, error) {
+	if err := r.wait(ctx, r.timeouts.Create); err != nil {
+		return nil, err
+	}
+	return r.svc.PerformAction(ctx, deviceID, actionInput)
+}
+
+func (r *rateLimitedDeviceSvc) CheckCapacity(
+	ctx context.Context,
+	servers []metalv1.ServerInfo,
+) (*metalv1.CapacityCheckPerMetroList, *http.Response, error) {
+	if err := r.wait(ctx, r.timeouts.Get); err != nil {
+		return nil, nil, err
+	}
+	return r.svc.CheckCapacity(ctx, servers)
+}
+
+func (r *rateLimitedDeviceSvc) FindPlans(
+	ctx context.Context,
+) (*metalv1.PlanList, *http.Response, error) {
+	if err := r.waitBulk(ctx, r.timeouts.List); err != nil {
+		return nil, nil, err
+	}
+	return r.svc.FindPlans(ctx)
+}
+
+func (r *rateLimitedDeviceSvc) FindOperatingSystems(
+	ctx context.Context,
+) (*metalv1.OperatingSystemList, *http.Response, error) {
+	if err := r.waitBulk(ctx, r.timeouts.List); err != nil {
+		return nil, nil, err
+	}
+	return r.svc.FindOperatingSystems(ctx)
+}
+
+func (r *rateLimitedDeviceSvc) FindProjectHardwareReservations(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.HardwareReservationList, *http.Response, error) {
+	if err := r.waitBulk(ctx, r.timeouts.List); err != nil {
+		return nil, nil, err
+	}
+	return r.svc.FindProjectHardwareReservations(ctx, projectID)
+}
+
+func (r *rateLimitedDeviceSvc) FindProjectSSHKeys(
+	ctx context.Context,
+	projectID string,
+) (*metalv1.SSHKeyList, *http.Response, error) {
+	if err := r.waitBulk(ctx, r.timeouts.List); err != nil {
+		return nil, nil, err
+	}
+	return r.svc.FindProjectSSHKeys(ctx, projectID)
+}
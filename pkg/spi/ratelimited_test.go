@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package spi_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/mock"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/spi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("RateLimitedSessionProvider", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	// calls that have to wait for the limiter fail right away, as the wait would exceed the deadline
+	rateLimited := ContainSubstring("would exceed context deadline")
+	expectNotRateLimited := func(err error) {
+		if err != nil {
+			ExpectWithOffset(1, err).NotTo(MatchError(rateLimited))
+		}
+	}
+	newSession := func(sessionProvider spi.SessionProviderInterface, token string) spi.MetalDeviceService {
+		svc, err := sessionProvider.NewSession(&corev1.Secret{
+			Data: map[string][]byte{"apiToken": []byte(token)},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return svc
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	})
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should limit the calls of all sessions using the same API token", func() {
+		sessionProvider := spi.NewRateLimitedSessionProvider(&mock.PluginSPIImpl{}, 1, 1, spi.Timeouts{})
+
+		_, _, err := newSession(sessionProvider, "token").FindProjectDevices(ctx, "abcdefg")
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = newSession(sessionProvider, "token").FindProjectDevices(ctx, "abcdefg")
+		Expect(err).To(MatchError(rateLimited))
+		_, _, err = newSession(sessionProvider, "other-token").FindProjectDevices(ctx, "abcdefg")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should keep a share of the rate for getting and deleting devices", func() {
+		svc := newSession(spi.NewRateLimitedSessionProvider(&mock.PluginSPIImpl{}, 1, 4, spi.Timeouts{}), "token")
+
+		_, _, err := svc.FindProjectDevices(ctx, "abcdefg")
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = svc.FindProjectDevices(ctx, "abcdefg")
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = svc.FindProjectDevices(ctx, "abcdefg")
+		Expect(err).To(MatchError(rateLimited))
+
+		_, _, err = svc.FindDeviceByID(ctx, "missing")
+		expectNotRateLimited(err)
+		_, err = svc.DeleteDevice(ctx, "missing")
+		expectNotRateLimited(err)
+		_, err = svc.DeleteDevice(ctx, "missing")
+		Expect(err).To(MatchError(rateLimited))
+	})
+
+	It("should wait for the limiter at most the timeout of the operation", func() {
+		svc := newSession(spi.NewRateLimitedSessionProvider(&mock.PluginSPIImpl{}, 0.1, 1, spi.Timeouts{Get: time.Second}), "token")
+
+		_, _, err := svc.FindDeviceByID(context.Background(), "missing")
+		expectNotRateLimited(err)
+		_, _, err = svc.FindDeviceByID(context.Background(), "missing")
+		Expect(err).To(MatchError(rateLimited))
+	})
+})
@@ -24,6 +24,13 @@ type MetalDeviceService interface {
 		createDeviceRequest metalv1.CreateDeviceRequest,
 	) (*metalv1.Device, *http.Response, error)
 	DeleteDevice(ctx context.Context, deviceID string) (*http.Response, error)
+	UpdateDevice(ctx context.Context, deviceID string, updateDeviceInput metalv1.DeviceUpdateInput) (*metalv1.Device, *http.Response, error)
+	PerformAction(ctx context.Context, deviceID string, actionInput metalv1.DeviceActionInput) (*http.Response, error)
+	CheckCapacity(ctx context.Context, servers []metalv1.ServerInfo) (*metalv1.CapacityCheckPerMetroList, *http.Response, error)
+	FindPlans(ctx context.Context) (*metalv1.PlanList, *http.Response, error)
+	FindOperatingSystems(ctx context.Context) (*metalv1.OperatingSystemList, *http.Response, error)
+	FindProjectHardwareReservations(ctx context.Context, projectID string) (*metalv1.HardwareReservationList, *http.Response, error)
+	FindProjectSSHKeys(ctx context.Context, projectID string) (*metalv1.SSHKeyList, *http.Response, error)
 }
 
 // SessionProviderInterface provides an interface to deal with cloud provider session
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package topology reads the metro and facility of an Equinix Metal device from the metadata service and labels the
+// node running on it with the corresponding topology labels.
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultMetadataURL is the URL of the metadata service as seen from a device
+const DefaultMetadataURL = "https://metadata.platformequinix.com/metadata"
+
+// Metadata are the fields of the metadata of a device that carry its topology
+type Metadata struct {
+	ID         string                 `json:"id"`
+	Hostname   string                 `json:"hostname"`
+	Metro      string                 `json:"metro"`
+	Facility   string                 `json:"facility"`
+	Tags       []string               `json:"tags"`
+	CustomData map[string]interface{} `json:"customdata"`
+}
+
+// FetchMetadata returns the metadata served at url
+func FetchMetadata(ctx context.Context, client *http.Client, url string) (*Metadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not get metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not get metadata: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read metadata: %w", err)
+	}
+	metadata := &Metadata{}
+	if err := json.Unmarshal(body, metadata); err != nil {
+		return nil, fmt.Errorf("could not decode metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// Labels returns the topology labels of the device, the region is its metro and the zone its facility. The fields of
+// the metadata take precedence over the tags and customdata stamped by the provider.
+func Labels(metadata *Metadata) (map[string]string, error) {
+	metro, facility := metadata.Metro, metadata.Facility
+	for _, tag := range api.ParseTags(metadata.Tags) {
+		switch {
+		case tag.Key == api.TagKeyMetro && metro == "":
+			metro = tag.Value
+		case tag.Key == api.TagKeyFacility && facility == "":
+			facility = tag.Value
+		}
+	}
+	if topology, ok := metadata.CustomData[api.CustomDataKeyTopology].(map[string]interface{}); ok {
+		if value, ok := topology["metro"].(string); ok && metro == "" {
+			metro = value
+		}
+		if value, ok := topology["facility"].(string); ok && facility == "" {
+			facility = value
+		}
+	}
+	if metro == "" || facility == "" {
+		return nil, fmt.Errorf("metadata of device %s carry no metro and facility", metadata.ID)
+	}
+	return map[string]string{
+		corev1.LabelTopologyRegion: metro,
+		corev1.LabelTopologyZone:   facility,
+	}, nil
+}
+
+// FormatLabels returns the labels sorted by key in the "key=value,..." form of the kubelet --node-labels flag
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// LabelNode adds the labels to the node, other labels are kept
+func LabelNode(ctx context.Context, client kubernetes.Interface, nodeName string, labels map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": labels,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := client.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("could not label node %s: %w", nodeName, err)
+	}
+	return nil
+}
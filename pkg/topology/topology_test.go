@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package topology_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/topology"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Topology", func() {
+	var (
+		server   *httptest.Server
+		metadata string
+	)
+
+	// the fake metadata service serves the metadata of a single device
+	BeforeEach(func() {
+		metadata = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/metadata" || metadata == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(metadata))
+		}))
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	labels := func() (map[string]string, error) {
+		md, err := topology.FetchMetadata(context.Background(), server.Client(), server.URL+"/metadata")
+		if err != nil {
+			return nil, err
+		}
+		return topology.Labels(md)
+	}
+
+	DescribeTable("should derive the topology labels",
+		func(served string, expected map[string]string) {
+			metadata = served
+			Expect(labels()).To(Equal(expected))
+		},
+		Entry("from the metadata", `{"id":"d1","hostname":"machine-1","metro":"ny","facility":"ny5"}`,
+			map[string]string{"topology.kubernetes.io/region": "ny", "topology.kubernetes.io/zone": "ny5"}),
+		Entry("from the stamped tags",
+			`{"id":"d1","tags":["equinixmetal.gardener.cloud/metro: da","equinixmetal.gardener.cloud/facility: da11"]}`,
+			map[string]string{"topology.kubernetes.io/region": "da", "topology.kubernetes.io/zone": "da11"}),
+		Entry("from the stamped customdata", `{"id":"d1","customdata":{"topology":{"metro":"sv","facility":"sv15"}}}`,
+			map[string]string{"topology.kubernetes.io/region": "sv", "topology.kubernetes.io/zone": "sv15"}),
+		Entry("preferring the metadata",
+			`{"id":"d1","metro":"ny","facility":"ny7","tags":["equinixmetal.gardener.cloud/facility: ny5"]}`,
+			map[string]string{"topology.kubernetes.io/region": "ny", "topology.kubernetes.io/zone": "ny7"}),
+	)
+
+	DescribeTable("should fail",
+		func(served string, expected string) {
+			metadata = served
+			_, err := labels()
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+		},
+		Entry("without metadata", "", "404 Not Found"),
+		Entry("for invalid metadata", `{"id":`, "could not decode metadata"),
+		Entry("without facility", `{"id":"d1","metro":"ny","tags":["equinixmetal.gardener.cloud/metro: ny"]}`,
+			"metadata of device d1 carry no metro and facility"),
+	)
+
+	It("should format the labels for the kubelet", func() {
+		Expect(topology.FormatLabels(map[string]string{
+			"topology.kubernetes.io/zone":   "ny5",
+			"topology.kubernetes.io/region": "ny",
+		})).To(Equal("topology.kubernetes.io/region=ny,topology.kubernetes.io/zone=ny5"))
+	})
+
+	It("should label the node and keep its other labels", func() {
+		client := fake.NewSimpleClientset(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine-1", Labels: map[string]string{"worker.gardener.cloud/pool": "a"}},
+		})
+		metadata = `{"id":"d1","hostname":"machine-1","metro":"ny","facility":"ny5"}`
+		nodeLabels, err := labels()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(topology.LabelNode(context.Background(), client, "machine-1", nodeLabels)).To(Succeed())
+		node, err := client.CoreV1().Nodes().Get(context.Background(), "machine-1", metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(node.Labels).To(Equal(map[string]string{
+			"worker.gardener.cloud/pool":    "a",
+			"topology.kubernetes.io/region": "ny",
+			"topology.kubernetes.io/zone":   "ny5",
+		}))
+	})
+
+	It("should fail for unknown nodes", func() {
+		client := fake.NewSimpleClientset()
+		Expect(topology.LabelNode(context.Background(), client, "machine-1", map[string]string{"a": "b"})).
+			To(MatchError(ContainSubstring("could not label node machine-1")))
+	})
+})
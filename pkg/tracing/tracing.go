@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tracing contains the OpenTelemetry tracing of the Equinix Metal provider.
+// Tracing is disabled unless Setup is called with an OTLP endpoint, the spans are then no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the name of the tracer of the provider
+	TracerName = "github.com/gardener/machine-controller-manager-provider-equinix-metal"
+
+	// AttributeMachine is the span attribute carrying the name of the Machine
+	AttributeMachine = attribute.Key("mcm.machine")
+	// AttributeMachineClass is the span attribute carrying the name of the MachineClass
+	AttributeMachineClass = attribute.Key("mcm.machineclass")
+	// AttributeProviderID is the span attribute carrying the ProviderID of the Machine
+	AttributeProviderID = attribute.Key("mcm.provider_id")
+	// AttributeDeviceID is the span attribute carrying the Equinix Metal device ID
+	AttributeDeviceID = attribute.Key("equinixmetal.device_id")
+	// AttributeProjectID is the span attribute carrying the Equinix Metal project ID
+	AttributeProjectID = attribute.Key("equinixmetal.project_id")
+	// AttributeRequestID is the span attribute carrying the request ID returned by the Equinix Metal API
+	AttributeRequestID = attribute.Key("equinixmetal.request_id")
+	// AttributeAction is the span attribute carrying the type of an action performed on a device
+	AttributeAction = attribute.Key("equinixmetal.action")
+
+	// otlpTracesPath is the path of the OTLP/HTTP traces endpoint
+	otlpTracesPath = "/v1/traces"
+)
+
+// Setup installs a global tracer provider exporting the spans via OTLP/HTTP to the endpoint, e.g.
+// "http://otel-collector:4318". The returned function flushes and stops the export.
+func Setup(ctx context.Context, serviceName, endpoint string) (func(context.Context) error, error) {
+	opts, err := exporterOptions(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceNameKey.String(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tracerProvider.Shutdown, nil
+}
+
+// exporterOptions converts the endpoint URL to the options of the OTLP/HTTP exporter
+func exporterOptions(endpoint string) ([]otlptracehttp.Option, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + otlpTracesPath),
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		opts = append(opts, otlptracehttp.WithInsecure())
+	default:
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: scheme must be http or https", endpoint)
+	}
+	return opts, nil
+}
+
+// Start starts a span of the provider tracer
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartClient starts a span of the provider tracer for a call of a remote service
+func StartClient(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...), trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// End records the error, if any, and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		server *httptest.Server
+		mu     sync.Mutex
+		paths  []string
+	)
+
+	BeforeEach(func() {
+		paths = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, r.URL.Path)
+		}))
+	})
+	AfterEach(func() {
+		server.Close()
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+
+	It("should export the spans to the OTLP/HTTP endpoint", func() {
+		shutdown, err := tracing.Setup(context.Background(), "test", server.URL+"/otlp/")
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, parent := tracing.Start(context.Background(), "parent")
+		_, child := tracing.StartClient(ctx, "child")
+		tracing.End(child, errors.New("failed"))
+		tracing.End(parent, nil)
+		Expect(shutdown(context.Background())).To(Succeed())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(paths).To(ConsistOf("/otlp/v1/traces"))
+	})
+
+	It("should reject endpoints without http or https scheme", func() {
+		_, err := tracing.Setup(context.Background(), "test", "otel-collector:4318")
+		Expect(err).To(MatchError(ContainSubstring("scheme must be http or https")))
+	})
+})
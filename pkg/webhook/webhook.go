@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package webhook contains the validating admission webhook for EquinixMetal MachineClasses and their secrets
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis/validation"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// PathMachineClass is the path serving the validation of MachineClasses
+	PathMachineClass = "/validate-machineclass"
+	// PathSecret is the path serving the validation of MachineClass secrets
+	PathSecret = "/validate-secret"
+	// PathHealthz is the path serving the health check
+	PathHealthz = "/healthz"
+
+	maxRequestBodyBytes = 3 * 1024 * 1024
+)
+
+// validateFunc validates the raw object of an admission request
+type validateFunc func(raw []byte) error
+
+// NewHandler returns the http handler serving the MachineClass and secret validation
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(PathMachineClass, admissionHandler(validateMachineClass))
+	mux.Handle(PathSecret, admissionHandler(validateSecret))
+	mux.HandleFunc(PathHealthz, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+func admissionHandler(validate validateFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodyBytes))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not read request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		review := &admissionv1.AdmissionReview{}
+		if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+			http.Error(w, fmt.Sprintf("could not decode admission review: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		response := &admissionv1.AdmissionResponse{
+			UID:     review.Request.UID,
+			Allowed: true,
+		}
+		// deletions cannot make a MachineClass invalid
+		if review.Request.Operation != admissionv1.Delete {
+			if err := validate(review.Request.Object.Raw); err != nil {
+				klog.V(2).InfoS("Rejected object", "kind", review.Request.Kind.Kind, "object", klog.KRef(review.Request.Namespace, review.Request.Name), "err", err)
+				response.Allowed = false
+				response.Result = &metav1.Status{
+					Status:  metav1.StatusFailure,
+					Code:    http.StatusUnprocessableEntity,
+					Reason:  metav1.StatusReasonInvalid,
+					Message: err.Error(),
+				}
+			}
+		}
+
+		review.Request = nil
+		review.Response = response
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			klog.ErrorS(err, "Could not write admission response")
+		}
+	}
+}
+
+func validateMachineClass(raw []byte) error {
+	machineClass := &v1alpha1.MachineClass{}
+	if err := json.Unmarshal(raw, machineClass); err != nil {
+		return fmt.Errorf("could not decode MachineClass: %v", err)
+	}
+	// MachineClasses of other providers are none of our business
+	if machineClass.Provider != provider.ProviderEquinixMetal {
+		return nil
+	}
+	return provider.ValidateMachineClass(machineClass)
+}
+
+func validateSecret(raw []byte) error {
+	secret := &corev1.Secret{}
+	if err := json.Unmarshal(raw, secret); err != nil {
+		return fmt.Errorf("could not decode Secret: %v", err)
+	}
+	if errs := validation.ValidateSecret(secret); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	return nil
+}
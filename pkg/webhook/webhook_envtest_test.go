@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("ValidatingWebhookConfiguration", func() {
+	ctx := context.Background()
+	newSpec := func(modify func(spec *api.EquinixMetalProviderSpec)) []byte {
+		spec := &api.EquinixMetalProviderSpec{
+			Metro:        "ny",
+			MachineType:  "c3.small.x86",
+			BillingCycle: "hourly",
+			OS:           "alpine_3",
+			ProjectID:    "abcdefg",
+			Tags: []string{
+				"kubernetes.io/cluster/shoot-test: 1",
+				"kubernetes.io/role/test: 1",
+			},
+		}
+		if modify != nil {
+			modify(spec)
+		}
+		raw, err := json.Marshal(spec)
+		Expect(err).NotTo(HaveOccurred())
+		return raw
+	}
+	newMachineClass := func(name, provider string, spec []byte) *v1alpha1.MachineClass {
+		return &v1alpha1.MachineClass{
+			ObjectMeta:   metav1.ObjectMeta{Name: name, Namespace: "default"},
+			ProviderSpec: runtime.RawExtension{Raw: spec},
+			Provider:     provider,
+		}
+	}
+	newSecret := func(name string, labels map[string]string, data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Data:       data,
+		}
+	}
+	machineClassSecretLabels := map[string]string{"equinixmetal.gardener.cloud/machineclass-secret": "true"}
+	expectRejected := func(err error, message string) {
+		Expect(apierrors.IsInvalid(err) || apierrors.IsForbidden(err)).To(BeTrue(), "unexpected error %v", err)
+		Expect(err.Error()).To(ContainSubstring(message))
+	}
+
+	BeforeEach(func() {
+		if testEnv == nil {
+			Skip("KUBEBUILDER_ASSETS is not set, the local API server cannot be started")
+		}
+	})
+
+	It("should admit valid MachineClasses and reject invalid ones on creation and update", func() {
+		machineClass := newMachineClass("valid", "EquinixMetal", newSpec(nil))
+		Expect(k8sClient.Create(ctx, machineClass)).To(Succeed())
+
+		machineClass.ProviderSpec.Raw = newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.BillingCycle = "weekly"
+		})
+		expectRejected(k8sClient.Update(ctx, machineClass), `providerSpec.billingCycle: Unsupported value: "weekly"`)
+
+		expectRejected(k8sClient.Create(ctx, newMachineClass("invalid", "EquinixMetal", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.Tags = nil
+		}))), "Tag required of the form kubernetes.io/cluster/****")
+
+		Expect(k8sClient.Delete(ctx, machineClass)).To(Succeed())
+	})
+
+	It("should only admit MachineClasses with machine types unknown to the embedded catalog if they allow them", func() {
+		machineClass := newMachineClass("unknown-machine-type", "EquinixMetal", newSpec(func(spec *api.EquinixMetalProviderSpec) {
+			spec.MachineType = "x9.large.x86"
+		}))
+		expectRejected(k8sClient.Create(ctx, machineClass), `providerSpec.machineType: Invalid value: "x9.large.x86": unknown machine type`)
+
+		machineClass.Annotations = map[string]string{api.AnnotationAllowUnknownCatalogValues: "true"}
+		Expect(k8sClient.Create(ctx, machineClass)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, machineClass)).To(Succeed())
+	})
+
+	It("should admit MachineClasses of other providers", func() {
+		machineClass := newMachineClass("other-provider", "AWS", []byte(`{"region":"eu-west-1"}`))
+		Expect(k8sClient.Create(ctx, machineClass)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, machineClass)).To(Succeed())
+	})
+
+	It("should only validate the secrets labeled as MachineClass secrets", func() {
+		expectRejected(k8sClient.Create(ctx, newSecret("invalid", machineClassSecretLabels, map[string][]byte{
+			"apiToken": []byte("token"),
+		})), "Required userData")
+
+		for _, secret := range []client.Object{
+			newSecret("valid", machineClassSecretLabels, map[string][]byte{"apiToken": []byte("token"), "userData": []byte("data")}),
+			newSecret("unlabeled", nil, map[string][]byte{"apiToken": []byte("token")}),
+		} {
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		}
+	})
+})
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook_test
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/webhook"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+var (
+	// testEnv is the local API server calling the webhook as configured in kubernetes/webhook.yaml, it is only
+	// started if KUBEBUILDER_ASSETS points to its binaries, e.g. as installed by setup-envtest
+	testEnv *envtest.Environment
+	// k8sClient is a client of the local API server
+	k8sClient client.Client
+	// webhookServer serves the webhook for the local API server
+	webhookServer *http.Server
+)
+
+func TestWebhook(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Webhook Suite")
+}
+
+var _ = BeforeSuite(func() {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		return
+	}
+	testEnv = &envtest.Environment{
+		CRDs: []*apiextensionsv1.CustomResourceDefinition{machineClassCRD()},
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{filepath.Join("..", "..", "kubernetes", "webhook.yaml")},
+		},
+	}
+	cfg, err := testEnv.Start()
+	Expect(err).NotTo(HaveOccurred())
+
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
+	Expect(err).NotTo(HaveOccurred())
+
+	options := testEnv.WebhookInstallOptions
+	handler := webhook.NewHandler()
+	webhookServer = &http.Server{
+		Addr: net.JoinHostPort(options.LocalServingHost, strconv.Itoa(options.LocalServingPort)),
+		// envtest joins the host and the service path with an additional slash
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.URL.Path = path.Clean(r.URL.Path)
+			handler.ServeHTTP(w, r)
+		}),
+	}
+	go func() {
+		defer GinkgoRecover()
+		err := webhookServer.ListenAndServeTLS(filepath.Join(options.LocalServingCertDir, "tls.crt"), filepath.Join(options.LocalServingCertDir, "tls.key"))
+		Expect(err).To(Equal(http.ErrServerClosed))
+	}()
+}, 120)
+
+var _ = AfterSuite(func() {
+	if testEnv == nil {
+		return
+	}
+	Expect(webhookServer.Close()).To(Succeed())
+	Expect(testEnv.Stop()).To(Succeed())
+})
+
+// machineClassCRD returns a CustomResourceDefinition of the MachineClasses of MCM accepting any fields
+func machineClassCRD() *apiextensionsv1.CustomResourceDefinition {
+	preserveUnknownFields := true
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "machineclasses.machine.sapcloud.io"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "machine.sapcloud.io",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:     "MachineClass",
+				ListKind: "MachineClassList",
+				Plural:   "machineclasses",
+				Singular: "machineclass",
+			},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:    "v1alpha1",
+				Served:  true,
+				Storage: true,
+				Schema: &apiextensionsv1.CustomResourceValidation{
+					OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+						Type:                   "object",
+						XPreserveUnknownFields: &preserveUnknownFields,
+					},
+				},
+			}},
+		},
+	}
+}
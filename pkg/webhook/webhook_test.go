@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	api "github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/provider/apis"
+	"github.com/gardener/machine-controller-manager-provider-equinix-metal/pkg/webhook"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Webhook", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(webhook.NewHandler())
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	providerSpec, _ := json.Marshal(api.EquinixMetalProviderSpec{
+		Metro:        "ny",
+		MachineType:  "c3.small.x86",
+		BillingCycle: "hourly",
+		OS:           "alpine_3",
+		ProjectID:    "abcdefg",
+		Tags: []string{
+			"kubernetes.io/cluster/shoot-test: 1",
+			"kubernetes.io/role/test: 1",
+		},
+	})
+	unknownPlanProviderSpec, _ := json.Marshal(api.EquinixMetalProviderSpec{
+		Metro:        "ny",
+		MachineType:  "c3.smal.x86",
+		BillingCycle: "hourly",
+		OS:           "alpine_3",
+		ProjectID:    "abcdefg",
+		Tags: []string{
+			"kubernetes.io/cluster/shoot-test: 1",
+			"kubernetes.io/role/test: 1",
+		},
+	})
+	invalidProviderSpec, _ := json.Marshal(api.EquinixMetalProviderSpec{
+		Metro:        "ny",
+		MachineType:  "c3.small.x86",
+		BillingCycle: "weekly",
+		OS:           "alpine_3",
+		ProjectID:    "abcdefg",
+	})
+	newMachineClass := func(provider string, spec []byte) runtime.Object {
+		return &v1alpha1.MachineClass{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "machine.sapcloud.io/v1alpha1", Kind: "MachineClass"},
+			ObjectMeta:   metav1.ObjectMeta{Name: "eqx-mc", Namespace: "default"},
+			ProviderSpec: runtime.RawExtension{Raw: spec},
+			Provider:     provider,
+		}
+	}
+	allowUnknownCatalogValues := func(obj runtime.Object) runtime.Object {
+		obj.(*v1alpha1.MachineClass).Annotations = map[string]string{api.AnnotationAllowUnknownCatalogValues: "true"}
+		return obj
+	}
+	newSecret := func(data map[string][]byte) runtime.Object {
+		return &corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+			Data:       data,
+		}
+	}
+
+	review := func(path string, operation admissionv1.Operation, obj runtime.Object) *admissionv1.AdmissionResponse {
+		raw, err := json.Marshal(obj)
+		Expect(err).ToNot(HaveOccurred())
+		body, err := json.Marshal(&admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       types.UID("1234"),
+				Operation: operation,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := http.Post(server.URL+path, "application/json", bytes.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		result := &admissionv1.AdmissionReview{}
+		Expect(json.NewDecoder(resp.Body).Decode(result)).To(Succeed())
+		Expect(result.Response).ToNot(BeNil())
+		Expect(result.Response.UID).To(Equal(types.UID("1234")))
+		return result.Response
+	}
+
+	DescribeTable("#MachineClass",
+		func(operation admissionv1.Operation, obj runtime.Object, allowed bool, message string) {
+			response := review(webhook.PathMachineClass, operation, obj)
+			Expect(response.Allowed).To(Equal(allowed))
+			if !allowed {
+				Expect(response.Result.Message).To(ContainSubstring(message))
+			}
+		},
+		Entry("valid", admissionv1.Create, newMachineClass("EquinixMetal", providerSpec), true, ""),
+		Entry("unknown machine type", admissionv1.Create, newMachineClass("EquinixMetal", unknownPlanProviderSpec), false,
+			`providerSpec.machineType: Invalid value: "c3.smal.x86": unknown machine type, did you mean one of`),
+		Entry("allowed unknown machine type", admissionv1.Create,
+			allowUnknownCatalogValues(newMachineClass("EquinixMetal", unknownPlanProviderSpec)), true, ""),
+		Entry("invalid", admissionv1.Update, newMachineClass("EquinixMetal", invalidProviderSpec), false,
+			`providerSpec.billingCycle: Unsupported value: "weekly"`),
+		Entry("missing tags", admissionv1.Create, newMachineClass("EquinixMetal", invalidProviderSpec), false,
+			"Tag required of the form kubernetes.io/cluster/****"),
+		Entry("other provider", admissionv1.Create, newMachineClass("AWS", invalidProviderSpec), true, ""),
+		Entry("deletion", admissionv1.Delete, newMachineClass("EquinixMetal", invalidProviderSpec), true, ""),
+	)
+
+	DescribeTable("#Secret",
+		func(obj runtime.Object, allowed bool, message string) {
+			response := review(webhook.PathSecret, admissionv1.Create, obj)
+			Expect(response.Allowed).To(Equal(allowed))
+			if !allowed {
+				Expect(response.Result.Message).To(ContainSubstring(message))
+			}
+		},
+		Entry("valid", newSecret(map[string][]byte{"apiToken": []byte("token"), "userData": []byte("data")}), true, ""),
+		Entry("alternate api token", newSecret(map[string][]byte{"alternateApiToken": []byte("token"), "userData": []byte("data")}), true, ""),
+		Entry("missing api token", newSecret(map[string][]byte{"userData": []byte("data")}), false,
+			"Required Equinix Metal API Key one of 'apiToken' or 'alternateApiToken'"),
+		Entry("missing userData", newSecret(map[string][]byte{"apiToken": []byte("token")}), false, "Required userData"),
+	)
+
+	It("should reject malformed reviews", func() {
+		resp, err := http.Post(server.URL+webhook.PathMachineClass, "application/json", bytes.NewReader([]byte("{")))
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})